@@ -0,0 +1,91 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"golang.org/x/term"
+)
+
+func runBackupCommand(ctx context.Context, engine *signer.Engine, action, path string) {
+	store := engine.Store
+	if path == "" {
+		log.Fatalf("usage: independent-signer backup export|restore <file>")
+	}
+
+	switch action {
+	case "export":
+		passphrase, err := readPassphrase("Passphrase to encrypt the backup with: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		publicKey, err := store.ExportBackup(path, passphrase)
+		if err != nil {
+			log.Fatalf("failed to export backup: %s", err)
+		}
+		log.Printf("Backup of Public Key %s written to %s\n", publicKey, path)
+	case "restore":
+		passphrase, err := readPassphrase("Passphrase of the backup: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		publicKey, err := store.RestoreBackup(path, passphrase)
+		if err != nil {
+			log.Fatalf("failed to restore backup: %s", err)
+		}
+		log.Printf("Public Key: %s\n", publicKey)
+		log.Printf("Restored %s into %s\n", path, store.Dir)
+
+		// A backup does not hold the prior block hash, it may be stale.
+		if _, err := engine.Recover(ctx); err != nil {
+			log.Printf("Failed to resume the independent chain from the NVL Proxy, the next run will retry: %s\n", err)
+		}
+	default:
+		log.Fatalf("unknown backup command %q", action)
+	}
+}
+
+// readPassphrase returns the passphrase from the environment, or prompts for
+// it on the terminal without echoing it. When stdin is not a terminal the
+// passphrase is read as a line from it.
+func readPassphrase(prompt string) (string, error) {
+	if passphrase, ok := os.LookupEnv(signer.BackupPassphraseEnv); ok {
+		return passphrase, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the passphrase: %w", err)
+		}
+		return string(passphrase), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read the passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
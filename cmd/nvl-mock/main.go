@@ -0,0 +1,233 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Command nvl-mock serves a mock NVL Proxy for running the independent signer
+// offline:
+//
+//	go run ./cmd/nvl-mock -addr 127.0.0.1:8545 -interval 1m
+//	independent-signer -nvlBaseURL http://127.0.0.1:8545
+//
+// Besides the NVL Proxy API it serves a few control endpoints:
+//
+//	POST /mock/blocks       produce a new proxy block (optional supply and children parameters)
+//	POST /mock/faults       set faults, e.g. ?badSignature=true&delay=5s
+//	POST /mock/rotate-key   switch the proxy to a new signing key
+//	POST /mock/claim        complete a pending registration, ?publicKey=<key>
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/nvlmock"
+)
+
+var (
+	addr     string
+	interval time.Duration
+	supply   string
+	children int
+	faults   nvlmock.Faults
+
+	requireRegistration bool
+
+	tlsCert  string
+	tlsKey   string
+	clientCA string
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", "127.0.0.1:8545", "Address to listen on")
+	flag.DurationVar(&interval, "interval", 0, "Produce a new proxy block at this interval (0 only produces one at startup)")
+	flag.StringVar(&supply, "supply", "1000000", "Coiin supply reported in produced proxy blocks")
+	flag.IntVar(&children, "children", 0, "Number of signed child blocks sealed by each produced proxy block")
+	flag.BoolVar(&faults.BadSignature, "badSignature", false, "Serve proxy blocks with corrupted signatures")
+	flag.BoolVar(&faults.BadChildSignature, "badChildSignature", false, "Serve child blocks with corrupted signatures")
+	flag.BoolVar(&faults.ServerError, "serverError", false, "Answer every API request with a 500")
+	flag.DurationVar(&faults.Delay, "delay", 0, "Delay added to every API response")
+	flag.DurationVar(&faults.ClockOffset, "clockOffset", 0, "Shift the proxy clock by this amount")
+	flag.BoolVar(&requireRegistration, "requireRegistration", false, "Refuse independent blocks from public keys that are not registered")
+	flag.StringVar(&tlsCert, "tlsCert", "", "Serve HTTPS with this PEM certificate")
+	flag.StringVar(&tlsKey, "tlsKey", "", "PEM key of -tlsCert")
+	flag.StringVar(&clientCA, "clientCA", "", "Require client certificates issued by this PEM CA (needs -tlsCert)")
+	flag.Parse()
+}
+
+func main() {
+	server, err := nvlmock.New()
+	if err != nil {
+		log.Fatalf("failed to create mock NVL Proxy: %s", err)
+	}
+	server.SetFaults(faults)
+	server.SetRequireRegistration(requireRegistration)
+	addBlock(server, supply, children)
+
+	if interval > 0 {
+		go func() {
+			for range time.Tick(interval) {
+				addBlock(server, supply, children)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", logRequests(server))
+	mux.HandleFunc("/mock/blocks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		blockSupply := supply
+		if param := r.URL.Query().Get("supply"); param != "" {
+			blockSupply = param
+		}
+		blockChildren := children
+		if param := r.URL.Query().Get("children"); param != "" {
+			n, err := strconv.Atoi(param)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid children", http.StatusBadRequest)
+				return
+			}
+			blockChildren = n
+		}
+		if hash := addBlock(server, blockSupply, blockChildren); hash == "" {
+			http.Error(w, "failed to produce block", http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/mock/faults", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		updated, err := parseFaults(server.Faults(), r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.SetFaults(updated)
+		log.Printf("Faults set to %+v\n", updated)
+	})
+	mux.HandleFunc("/mock/rotate-key", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := server.RotateKey(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Proxy public key rotated to %s\n", server.PublicKey())
+	})
+	mux.HandleFunc("/mock/claim", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		publicKey := r.URL.Query().Get("publicKey")
+		if err := server.ClaimRegistration(publicKey); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Registration of %s claimed\n", publicKey)
+	})
+
+	if tlsCert == "" {
+		log.Printf("Mock NVL Proxy listening on http://%s with public key %s\n", addr, server.PublicKey())
+		log.Printf("Child blocks are signed by %s\n", server.ChildPublicKey())
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}
+
+	httpServer := &http.Server{Addr: addr, Handler: mux}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			log.Fatalf("failed to read client CA: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("client CA %s contains no certificates", clientCA)
+		}
+		httpServer.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	log.Printf("Mock NVL Proxy listening on https://%s with public key %s\n", addr, server.PublicKey())
+	log.Printf("Child blocks are signed by %s\n", server.ChildPublicKey())
+	log.Fatal(httpServer.ListenAndServeTLS(tlsCert, tlsKey))
+}
+
+func addBlock(server *nvlmock.Server, supply string, children int) string {
+	childHashes := make([]string, 0, children)
+	for i := 0; i < children; i++ {
+		childHash, err := server.AddChildBlock("L1")
+		if err != nil {
+			log.Printf("failed to produce child block: %s", err)
+			return ""
+		}
+		childHashes = append(childHashes, childHash)
+	}
+
+	hash, err := server.AddBlock(supply, childHashes...)
+	if err != nil {
+		log.Printf("failed to produce proxy block: %s", err)
+		return ""
+	}
+	log.Printf("Produced proxy block %s\n", hash)
+	return hash
+}
+
+func parseFaults(current nvlmock.Faults, r *http.Request) (nvlmock.Faults, error) {
+	query := r.URL.Query()
+	var err error
+	if param := query.Get("badSignature"); param != "" {
+		if current.BadSignature, err = strconv.ParseBool(param); err != nil {
+			return current, err
+		}
+	}
+	if param := query.Get("badChildSignature"); param != "" {
+		if current.BadChildSignature, err = strconv.ParseBool(param); err != nil {
+			return current, err
+		}
+	}
+	if param := query.Get("serverError"); param != "" {
+		if current.ServerError, err = strconv.ParseBool(param); err != nil {
+			return current, err
+		}
+	}
+	if param := query.Get("delay"); param != "" {
+		if current.Delay, err = time.ParseDuration(param); err != nil {
+			return current, err
+		}
+	}
+	if param := query.Get("clockOffset"); param != "" {
+		if current.ClockOffset, err = time.ParseDuration(param); err != nil {
+			return current, err
+		}
+	}
+	return current, nil
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s\n", r.Method, r.URL.RequestURI())
+		next.ServeHTTP(w, r)
+	})
+}
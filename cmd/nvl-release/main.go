@@ -0,0 +1,169 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Command nvl-release signs signer releases for self-update and serves them
+// for testing:
+//
+//	go run ./cmd/nvl-release keygen release.key
+//	go run ./cmd/nvl-release sign -key release.key -version v1.2.0 -out build/release \
+//	    linux_amd64=build/independent-signer_linux_amd64
+//	go run ./cmd/nvl-release serve -addr 127.0.0.1:8546 build/release
+//
+// keygen prints the release public key to build the signer with, e.g.
+// make RELEASE_PUBLIC_KEY=04...; sign copies each platform executable to the
+// output directory with a detached signature and writes release.json.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/update"
+)
+
+func main() {
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "keygen":
+		if flag.NArg() != 2 {
+			log.Fatalf("usage: nvl-release keygen <key file>")
+		}
+		publicKey, err := keygen(flag.Arg(1))
+		if err != nil {
+			log.Fatalf("failed to generate release key: %s", err)
+		}
+		log.Printf("Release Public Key: %s\n", publicKey)
+	case "sign":
+		runSign(flag.Args()[1:])
+	case "serve":
+		runServe(flag.Args()[1:])
+	default:
+		log.Fatalf("usage: nvl-release keygen|sign|serve")
+	}
+}
+
+func keygen(path string) (string, error) {
+	releaseKey, err := crypto.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	if err := signer.CreateFileAtomic(path, []byte(hex.EncodeToString(crypto.FromECDSA(releaseKey))), 0600); err != nil {
+		return "", err
+	}
+	return signer.PublicKeyHex(releaseKey), nil
+}
+
+func runSign(args []string) {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := flags.String("key", "", "File holding the hex encoded release private key")
+	version := flags.String("version", "", "Version of the release, e.g. v1.2.0")
+	out := flags.String("out", "", "Directory the release is written to")
+	flags.Parse(args)
+
+	if *keyFile == "" || *version == "" || *out == "" || flags.NArg() == 0 {
+		log.Fatalf("usage: nvl-release sign -key <key file> -version <version> -out <directory> <platform>=<executable>...")
+	}
+	if _, err := update.CompareVersions(*version, *version); err != nil {
+		log.Fatal(err)
+	}
+	keyData, err := os.ReadFile(*keyFile)
+	if err != nil {
+		log.Fatalf("failed to read release key: %s", err)
+	}
+	releaseKey, err := crypto.HexToECDSA(strings.TrimSpace(string(keyData)))
+	if err != nil {
+		log.Fatalf("invalid release key: %s", err)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	release := &update.Release{Version: *version, Executables: make(map[string]*update.Executable)}
+	for _, arg := range flags.Args() {
+		platform, path, ok := strings.Cut(arg, "=")
+		if !ok {
+			log.Fatalf("invalid executable %q, expected <platform>=<executable>", arg)
+		}
+		executable, err := sign(releaseKey, *version, platform, *out, path)
+		if err != nil {
+			log.Fatalf("failed to sign %s: %s", path, err)
+		}
+		release.Executables[platform] = executable
+		log.Printf("Signed %s for %s\n", path, platform)
+	}
+
+	data, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	metadataPath := filepath.Join(*out, update.MetadataFilename)
+	if err := signer.WriteFileAtomic(metadataPath, append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Release %s written to %s\n", *version, metadataPath)
+}
+
+// sign copies the executable at path to out with its signature, as version for
+// platform, next to it.
+func sign(releaseKey *ecdsa.PrivateKey, version, platform, out, path string) (*update.Executable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := update.Sign(releaseKey, version, platform, data)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	if err := signer.WriteFileAtomic(filepath.Join(out, name), data, 0755); err != nil {
+		return nil, err
+	}
+	if err := signer.WriteFileAtomic(filepath.Join(out, name+".sig"), []byte(signature), 0644); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return &update.Executable{
+		URL:          name,
+		SHA256:       hex.EncodeToString(digest[:]),
+		SignatureURL: name + ".sig",
+	}, nil
+}
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8546", "Address to listen on")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("usage: nvl-release serve -addr <address> <directory>")
+	}
+	log.Printf("Serving the release in %s on http://%s/%s\n", flags.Arg(0), *addr, update.MetadataFilename)
+	log.Fatal(http.ListenAndServe(*addr, http.FileServer(http.Dir(flags.Arg(0)))))
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Command nvl-vectors checks or regenerates the canonical-encoding
+// conformance vectors in pkg/signer/testdata/vectors.json:
+//
+//	go run ./cmd/nvl-vectors verify pkg/signer/testdata/vectors.json
+//	go run ./cmd/nvl-vectors generate pkg/signer/testdata/vectors.json
+//
+// Regenerating is only needed when a case is added. A verify failure means the
+// signer no longer produces signatures the NVL Proxy will accept.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// vectorPrivateKey is a well known test key. It is published with the vectors
+// and must never hold anything of value.
+const vectorPrivateKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+
+const hash1 = "6fffb8b8aa66af313d884f4194383261bbd05cb030313f31dd1a4fa5385d47de"
+const hash2 = "56e74f9f918ca2adcfd446c3e3379630036db4abc58562f22495b675e114a38f"
+
+var cases = []struct {
+	name, description, block string
+}{
+	{
+		"proxy-block",
+		"A typical proxy block sealing two blocks, with a coiinSupply.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"` + hash1 + `","timestamp":"1697700000","publicKey":"04aa","coiinSupply":"1000000000"},"blocks":["` + hash1 + `","` + hash2 + `"]}`,
+	},
+	{
+		"independent-block",
+		"An independent block attesting a single proxy block.",
+		`{"version":"1","header":{"type":"INDEPENDENT","priorBlock":"` + hash2 + `","timestamp":"1697700123","publicKey":"04bb","coiinSupply":"1000000000"},"blocks":["` + hash1 + `"]}`,
+	},
+	{
+		"first-independent-block",
+		"The first block of an independent chain has an empty priorBlock, which is kept.",
+		`{"version":"1","header":{"type":"INDEPENDENT","priorBlock":"","timestamp":"1697700123","publicKey":"04bb","coiinSupply":"1"},"blocks":["` + hash1 + `"]}`,
+	},
+	{
+		"missing-coiin-supply",
+		"A header without coiinSupply omits the key from the payload.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"` + hash1 + `","timestamp":"1697700000","publicKey":"04aa"},"blocks":["` + hash2 + `"]}`,
+	},
+	{
+		"empty-coiin-supply",
+		"An empty coiinSupply is treated exactly like a missing one.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"` + hash1 + `","timestamp":"1697700000","publicKey":"04aa","coiinSupply":""},"blocks":["` + hash2 + `"]}`,
+	},
+	{
+		"empty-blocks",
+		"An empty blocks list is encoded as [].",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa","coiinSupply":"5"},"blocks":[]}`,
+	},
+	{
+		"null-blocks",
+		"A null blocks list is encoded as [], the same as an empty one.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa","coiinSupply":"5"},"blocks":null}`,
+	},
+	{
+		"missing-blocks",
+		"A missing blocks list is encoded as [], the same as an empty one.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa","coiinSupply":"5"}}`,
+	},
+	{
+		"block-order",
+		"The order of the blocks list is preserved, not sorted.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa"},"blocks":["` + hash2 + `","` + hash1 + `"]}`,
+	},
+	{
+		"unicode",
+		"Non-ASCII characters are written as raw UTF-8, not escaped.",
+		`{"version":"1","header":{"type":"PRÖXY ⛓ 🌧","priorBlock":"","timestamp":"1697700000","publicKey":"04aa"},"blocks":["ブロック"]}`,
+	},
+	{
+		"html-escaping",
+		"<, > and & are escaped as \\u003c, \\u003e and \\u0026, and U+2028 and U+2029 as \\u2028 and \\u2029.",
+		`{"version":"1","header":{"type":"<a&b>","priorBlock":"","timestamp":"1697700000","publicKey":"04aa"},"blocks":["\u2028\u2029"]}`,
+	},
+	{
+		"control-characters",
+		"Quotes, backslashes and control characters are escaped.",
+		`{"version":"1","header":{"type":"\"\\\n\t\u0001","priorBlock":"","timestamp":"1697700000","publicKey":"04aa"},"blocks":[]}`,
+	},
+	{
+		"seal-excluded",
+		"The seal is not part of the payload.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa"},"blocks":[],"signature":{"proofs":"` + hash1 + `","signature":"00"}}`,
+	},
+	{
+		"unknown-header-fields",
+		"Header fields the signer does not know are kept in the payload with their JSON values, and sorted with the others.",
+		`{"version":"1","header":{"type":"PROXY","priorBlock":"","timestamp":"1697700000","publicKey":"04aa","zone":"eu","epoch":12,"aux":{"b":[1.50,true,null],"a":"x"}},"blocks":[]}`,
+	},
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		log.Fatalf("usage: nvl-vectors verify|generate <file>")
+	}
+	path := flag.Arg(1)
+
+	switch flag.Arg(0) {
+	case "verify":
+		if err := verify(path); err != nil {
+			log.Fatalf("conformance vectors failed:\n%s", err)
+		}
+		log.Printf("All conformance vectors in %s passed\n", path)
+	case "generate":
+		if err := generate(path); err != nil {
+			log.Fatalf("failed to generate conformance vectors: %s", err)
+		}
+		log.Printf("Wrote %d conformance vectors to %s\n", len(cases), path)
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
+	}
+}
+
+func verify(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	set := new(signer.VectorSet)
+	if err := json.Unmarshal(data, set); err != nil {
+		return err
+	}
+	if len(set.Vectors) == 0 {
+		return fmt.Errorf("%s contains no vectors", path)
+	}
+	return set.Check()
+}
+
+func generate(path string) error {
+	signingKey, err := crypto.HexToECDSA(vectorPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	set := &signer.VectorSet{
+		PrivateKey: vectorPrivateKey,
+		PublicKey:  signer.PublicKeyHex(signingKey),
+	}
+	for _, c := range cases {
+		vector, err := signer.NewVector(c.name, c.description, json.RawMessage(c.block), signingKey)
+		if err != nil {
+			return err
+		}
+		set.Vectors = append(set.Vectors, vector)
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(set); err != nil {
+		return err
+	}
+	return signer.WriteFileAtomic(path, data.Bytes(), 0644)
+}
@@ -2,12 +2,15 @@ module github.com/Coiin-Blockchain/nvl-independent-signer
 
 go 1.20
 
-require github.com/ethereum/go-ethereum v1.12.0
+require (
+	github.com/ethereum/go-ethereum v1.12.0
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.11.0
+	golang.org/x/term v0.10.0
+)
 
 require (
 	github.com/btcsuite/btcd/btcec/v2 v2.2.0 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
 	github.com/holiman/uint256 v1.2.2-0.20230321075855-87b91420868c // indirect
-	golang.org/x/crypto v0.1.0 // indirect
-	golang.org/x/sys v0.11.0 // indirect
 )
@@ -0,0 +1,180 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/systemd"
+)
+
+func runInstallCommand(dataDir string, args []string) {
+	flags := flag.NewFlagSet("install", flag.ExitOnError)
+	system := flags.Bool("system", false, "Install for every user as a system service instead of for the current user (Linux only)")
+	interval := flags.Duration("interval", install.DefaultSettings.Interval, "How often the signer runs")
+	runAtLoad := flags.Bool("runAtLoad", install.DefaultSettings.RunAtLoad, "Also run the signer at login or boot")
+	onACPower := flags.Bool("onACPower", install.DefaultSettings.OnACPower, "Skip runs while the computer is on battery (Windows and Linux)")
+	whenIdle := flags.Bool("whenIdle", install.DefaultSettings.WhenIdle, "Only run the signer while the computer is idle (Windows only)")
+	unitDir := flags.String("unitDir", "", "Only write the systemd units to this directory, e.g. for packaging")
+	execPath := flags.String("execPath", "", "Path of the installed executable (defaults to this executable)")
+	restore := flags.String("restore", "", "Backup to restore the signing key and state from")
+	flags.Parse(args)
+
+	settings := install.Settings{Interval: *interval, RunAtLoad: *runAtLoad, OnACPower: *onACPower, WhenIdle: *whenIdle}
+	options := install.Options{DataDir: dataDir, ExecPath: *execPath, Settings: settings, System: *system}
+	if *restore != "" {
+		passphrase, err := readPassphrase("Passphrase of the backup: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.RestoreFrom, options.RestorePassphrase = *restore, passphrase
+	}
+	if *system {
+		options.DataDir = ""
+	}
+
+	if *unitDir != "" {
+		if options.ExecPath == "" {
+			log.Fatalf("-unitDir needs -execPath")
+		}
+		if *whenIdle {
+			log.Fatalf("systemd timers cannot wait until the computer is idle")
+		}
+		units := systemd.Options{System: *system, ExecPath: options.ExecPath, Interval: *interval, RunAtBoot: *runAtLoad, OnACPower: *onACPower}
+		if err := systemd.WriteUnits(*unitDir, units); err != nil {
+			log.Fatalf("failed to write units: %s", err)
+		}
+		log.Printf("Units written to %s\n", *unitDir)
+		return
+	}
+
+	if *system && options.ExecPath == "" {
+		// The system service cannot read home directories, so the
+		// executable is copied out of them.
+		path, err := os.Executable()
+		if err != nil {
+			log.Fatalf("failed to find the executable: %s", err)
+		}
+		if !strings.HasPrefix(path, "/usr/") {
+			if options.Executable, err = os.ReadFile(path); err != nil {
+				log.Fatalf("failed to read the executable: %s", err)
+			}
+			path = systemd.SystemExecPath
+		}
+		options.ExecPath = path
+	}
+
+	// Reinstalling keeps the current schedule, except for the flags given.
+	if schedule, err := install.ScheduleStatus(options); err != nil {
+		log.Printf("Failed to read the current schedule: %s\n", err)
+	} else if schedule != nil && schedule.Settings != nil {
+		log.Printf("Current schedule: %s\n", schedule.Settings)
+		given := make(map[string]bool)
+		flags.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+		})
+		current := *schedule.Settings
+		if !given["interval"] {
+			options.Settings.Interval = current.Interval
+		}
+		if !given["runAtLoad"] {
+			options.Settings.RunAtLoad = current.RunAtLoad
+		}
+		if !given["onACPower"] {
+			options.Settings.OnACPower = current.OnACPower
+		}
+		if !given["whenIdle"] {
+			options.Settings.WhenIdle = current.WhenIdle
+		}
+	}
+
+	result, err := install.Install(options)
+	if err != nil {
+		log.Fatalf("failed to install: %s", err)
+	}
+	log.Printf("Installed %s with the %s, it runs %s\n", result.ExecPath, result.Scheduler, result.Settings)
+	if result.NewKey {
+		log.Println("New signing key generated")
+	} else if result.Restored {
+		log.Printf("Signing key restored from %s\n", *restore)
+	}
+	log.Printf("Public Key: %s\n", result.PublicKey)
+	log.Printf("The Public Key was saved to %s\n", result.PublicKeyFile)
+}
+
+func runUninstallCommand(dataDir string, args []string) {
+	flags := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	system := flags.Bool("system", false, "Remove the system service instead of the one of the current user (Linux only)")
+	removeData := flags.Bool("removeData", false, "Also delete the data directory and the signing key in it")
+	flags.Parse(args)
+
+	options := install.Options{DataDir: dataDir, System: *system, RemoveData: *removeData}
+	if *system {
+		options.DataDir = systemd.SystemDataDir
+	}
+	if err := install.Uninstall(options); err != nil {
+		log.Fatalf("failed to uninstall: %s", err)
+	}
+	if *removeData {
+		log.Printf("Uninstalled the independent signer and deleted %s\n", options.DataDir)
+		return
+	}
+	log.Printf("Uninstalled the independent signer. The signing key was kept in %s\n", options.DataDir)
+}
+
+// printSchedule prints the status of the systemd timers, if any are
+// installed.
+func printSchedule() {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	for _, system := range []bool{true, false} {
+		status, err := systemd.Status(system)
+		if err != nil {
+			fmt.Printf("Schedule:       unknown (%s)\n", err)
+			continue
+		} else if status == nil {
+			continue
+		}
+
+		scope := "user"
+		if status.System {
+			scope = "system"
+		}
+		fmt.Printf("Schedule:       systemd %s timer, %s, %s\n", scope, status.Enabled, status.ActiveState)
+		schedule, err := install.ScheduleStatus(install.Options{System: system})
+		if err == nil && schedule != nil && schedule.Settings != nil {
+			fmt.Printf("Runs:           %s\n", schedule.Settings)
+		}
+		fmt.Printf("Last trigger:   %s (%s)\n", orUnknown(status.LastRun), orUnknown(status.Result))
+		fmt.Printf("Next trigger:   %s\n", orUnknown(status.NextRun))
+	}
+}
+
+func orUnknown(value string) string {
+	if value == "" || value == "n/a" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// backupFilename is the file name suggested for new backups.
+const backupFilename = "independent-signer-backup.json"
+
+// confirmUpgrade offers a backup of the existing signing key before upgrading.
+func (g *GUI) confirmUpgrade(existingKey string) {
+	upgrade := func() {
+		// The current schedule is offered again. If it cannot be read,
+		// the defaults are.
+		current, _ := g.installer.CurrentSettings()
+		g.chooseSchedule(current, func() {
+			g.install(true, g.installer.Install)
+		})
+	}
+	if existingKey == "" {
+		upgrade()
+		return
+	}
+	g.offerBackup(fmt.Sprintf("Upgrading keeps your signing key, with the Public Key:\n%s\n"+
+		"You can export an encrypted backup of it first.", existingKey), upgrade)
+}
+
+// confirmUninstall offers a backup of the signing key, then asks whether to
+// keep it. It is kept unless deleting it is confirmed.
+func (g *GUI) confirmUninstall(existingKey string) {
+	if existingKey == "" {
+		g.uninstall(false)
+		return
+	}
+	g.offerBackup(fmt.Sprintf("Your signing key, with the Public Key:\n%s\n"+
+		"is kept when uninstalling, so reinstalling signs with the same Public Key.\n"+
+		"You can export an encrypted backup of it first.", existingKey), func() {
+		removeData := widget.NewCheck("Also delete my signing key. It cannot be recovered without a backup.", nil)
+		g.w.SetContent(container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Uninstall %s?", g.installer.AppName)),
+			removeData,
+			widget.NewButton("Uninstall", func() {
+				if !removeData.Checked {
+					g.uninstall(false)
+					return
+				}
+				dialog.ShowConfirm("Delete the signing key?",
+					"Blocks can no longer be signed with your registered Public Key.\nThis cannot be undone without a backup.",
+					func(confirmed bool) {
+						if confirmed {
+							g.uninstall(true)
+						}
+					}, g.w)
+			}),
+			g.closeButton(),
+		))
+		g.w.Resize(fyne.NewSize(0, 0))
+	})
+}
+
+// offerBackup shows message with a form to export an encrypted backup, then
+// calls next once the backup is written or skipped.
+func (g *GUI) offerBackup(message string, next func()) {
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("Backup passphrase")
+	confirmation := widget.NewPasswordEntry()
+	confirmation.SetPlaceHolder("Repeat the passphrase")
+
+	export := func() {
+		if passphrase.Text != confirmation.Text {
+			dialog.ShowError(errors.New("the passphrases do not match"), g.w)
+			return
+		}
+		if len(passphrase.Text) < signer.MinBackupPassphraseLength {
+			dialog.ShowError(fmt.Errorf("the passphrase must be at least %d characters", signer.MinBackupPassphraseLength), g.w)
+			return
+		}
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.w)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			path := writer.URI().Path()
+			writer.Close()
+			if _, err := g.installer.ExportBackup(path, passphrase.Text); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to export the backup: %w", err), g.w)
+				return
+			}
+			dialog.ShowInformation("Backup exported",
+				fmt.Sprintf("The backup was saved to %s.\nKeep it and its passphrase somewhere safe.", path), g.w)
+			next()
+		}, g.w)
+		save.SetFileName(backupFilename)
+		save.Show()
+	}
+
+	g.w.SetContent(container.NewVBox(
+		widget.NewLabel(message),
+		passphrase,
+		confirmation,
+		widget.NewButton("Export an encrypted backup and continue", export),
+		widget.NewButton("Continue without a backup", next),
+	))
+	g.w.Resize(fyne.NewSize(0, 0))
+}
+
+// chooseBackup asks for a backup and its passphrase, then installs with the
+// signing key restored from it.
+func (g *GUI) chooseBackup() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.w)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		path := reader.URI().Path()
+		reader.Close()
+
+		backup, err := signer.ReadBackup(path)
+		if err != nil {
+			dialog.ShowError(err, g.w)
+			return
+		}
+		passphrase := widget.NewPasswordEntry()
+		passphrase.SetPlaceHolder("Backup passphrase")
+		g.w.SetContent(container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Restore the signing key with the Public Key:\n%s", backup.PublicKey)),
+			passphrase,
+			widget.NewButton("Restore and install", func() {
+				g.chooseSchedule(nil, func() {
+					g.install(false, func() (*install.Result, error) {
+						return g.installer.Restore(path, passphrase.Text)
+					})
+				})
+			}),
+			g.closeButton(),
+		))
+		g.w.Resize(fyne.NewSize(0, 0))
+	}, g.w)
+}
@@ -0,0 +1,264 @@
+// Package gui is the Fyne installer shared by the macOS and Windows
+// installers. Everything platform specific is behind an install.Platform.
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+)
+
+// consoleURL is the page of the Coiin Console where nodes are registered.
+var consoleURL = &url.URL{
+	Scheme: "https",
+	Host:   "coiin.ai",
+	Path:   "/verificationnodes",
+}
+
+// Installer installs an embedded signer executable.
+type Installer struct {
+	AppName    string
+	Version    string
+	SignerName string
+	Signer     []byte
+	Platform   install.Platform
+	// Settings are when the installed signer runs.
+	Settings install.Settings
+	// Config, if set, replaces the configuration file of the signer.
+	Config []byte
+}
+
+// NewInstaller returns an installer that installs signer, under the file name
+// signerName, with platform.
+func NewInstaller(appName, version, signerName string, signer []byte, platform install.Platform) *Installer {
+	return &Installer{
+		AppName:    appName,
+		Version:    version,
+		SignerName: signerName,
+		Signer:     signer,
+		Platform:   platform,
+		Settings:   install.DefaultSettings,
+	}
+}
+
+func (i *Installer) options() install.Options {
+	return install.Options{Executable: i.Signer, ExecutableName: i.SignerName, Platform: i.Platform, Settings: i.Settings, Config: i.Config}
+}
+
+// IsInstalled reports whether the signer is scheduled.
+func (i *Installer) IsInstalled() (bool, error) {
+	return install.Installed(i.options())
+}
+
+// Install writes the signer, generates the signing key if there is none yet
+// and schedules the signer with i.Settings.
+func (i *Installer) Install() (*install.Result, error) {
+	return install.Install(i.options())
+}
+
+// Restore installs like Install, with the signing key and state restored from
+// a backup.
+func (i *Installer) Restore(backupPath, passphrase string) (*install.Result, error) {
+	options := i.options()
+	options.RestoreFrom, options.RestorePassphrase = backupPath, passphrase
+	return install.Install(options)
+}
+
+// Uninstall removes the signer from the scheduler. The signing key is kept
+// unless removeData is set.
+func (i *Installer) Uninstall(removeData bool) error {
+	options := i.options()
+	options.RemoveData = removeData
+	return install.Uninstall(options)
+}
+
+// ExistingKey returns the public key of a signing key left by a previous
+// install, or an empty string if there is none.
+func (i *Installer) ExistingKey() (string, error) {
+	return install.ExistingKey(i.options())
+}
+
+// ExportBackup writes an encrypted backup of the signing key and state to
+// path.
+func (i *Installer) ExportBackup(path, passphrase string) (string, error) {
+	return install.ExportBackup(i.options(), path, passphrase)
+}
+
+// CopyPublicKey copies the public key of the installed signer to the
+// clipboard and returns it.
+func (i *Installer) CopyPublicKey() (string, error) {
+	publicKey, err := install.ExistingKey(i.options())
+	if err != nil {
+		return "", err
+	} else if publicKey == "" {
+		return "", errors.New("no signing key has been generated yet")
+	}
+	if err := i.Platform.CopyToClipboard(publicKey); err != nil {
+		return "", err
+	}
+	return publicKey, nil
+}
+
+// GUI is the installer window.
+type GUI struct {
+	installer *Installer
+	a         fyne.App
+	w         fyne.Window
+}
+
+// NewGUI returns the window of installer.
+func NewGUI(installer *Installer) *GUI {
+	a := app.New()
+	return &GUI{
+		installer: installer,
+		a:         a,
+		w:         a.NewWindow(installer.AppName),
+	}
+}
+
+// Run shows the installer and returns when it is closed.
+func (g *GUI) Run() {
+	g.w.Resize(fyne.NewSize(0, 0))
+	appName := g.installer.AppName
+
+	isInstalled, err := g.installer.IsInstalled()
+	var existingKey string
+	if err == nil {
+		existingKey, err = g.installer.ExistingKey()
+	}
+	if err != nil {
+		g.showMessage(fmt.Sprintf("An error occurred while checking if %s is installed: %s", appName, err))
+	} else if isInstalled {
+		label := widget.NewLabel(fmt.Sprintf("%s is already installed.\nWhat do you want to do?", appName))
+		g.w.SetContent(container.NewVBox(
+			label,
+			widget.NewButton(fmt.Sprintf("1. Override the current version with %s", g.installer.Version), func() {
+				g.confirmUpgrade(existingKey)
+			}),
+			widget.NewButton("2. Uninstall the current version", func() {
+				g.confirmUninstall(existingKey)
+			}),
+			widget.NewButton("3. Copy your Public Key to the clipboard", func() {
+				g.copyPublicKey()
+			}),
+			widget.NewButton("4. Show the node status", func() {
+				g.showStatus()
+			}),
+		))
+	} else if existingKey != "" {
+		label := widget.NewLabel(fmt.Sprintf("Do you want to install the %s - %s?\n"+
+			"The signing key of a previous install will be used, with the Public Key:\n%s", appName, g.installer.Version, existingKey))
+		g.w.SetContent(container.NewVBox(
+			label,
+			widget.NewButton("1. Yes", func() {
+				g.chooseSchedule(nil, func() {
+					g.install(false, g.installer.Install)
+				})
+			}),
+		))
+	} else {
+		label := widget.NewLabel(fmt.Sprintf("Do you want to install the %s - %s?", appName, g.installer.Version))
+		g.w.SetContent(container.NewVBox(
+			label,
+			widget.NewButton("1. Yes", func() {
+				g.chooseSchedule(nil, func() {
+					g.install(false, g.installer.Install)
+				})
+			}),
+			widget.NewButton("2. Restore my signing key from a backup", func() {
+				g.chooseBackup()
+			}),
+		))
+	}
+
+	g.w.ShowAndRun()
+}
+
+func (g *GUI) closeButton() *widget.Button {
+	return widget.NewButton("Close", func() {
+		g.a.Quit()
+	})
+}
+
+// showMessage replaces the content of the window with message and a close
+// button.
+func (g *GUI) showMessage(message string) {
+	g.w.SetContent(container.NewVBox(
+		widget.NewLabel(message),
+		g.closeButton(),
+	))
+	g.w.Resize(fyne.NewSize(0, 0))
+}
+
+func (g *GUI) uninstall(removeData bool) {
+	appName := g.installer.AppName
+	if err := g.installer.Uninstall(removeData); err != nil {
+		g.showMessage(fmt.Sprintf("An error occurred while uninstalling %s: %s", appName, err))
+		return
+	}
+	if removeData {
+		g.showMessage(fmt.Sprintf("%s was uninstalled successfully and your signing key was deleted.", appName))
+		return
+	}
+	g.showMessage(fmt.Sprintf("%s was uninstalled successfully!\nYour signing key was kept, reinstalling will use the same Public Key.", appName))
+}
+
+// install runs installFunc, which is Installer.Install or a restore, and shows
+// the public key.
+func (g *GUI) install(isUpgrade bool, installFunc func() (*install.Result, error)) {
+	appName := g.installer.AppName
+	g.w.SetContent(container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Installing %s ...", appName)),
+		widget.NewProgressBarInfinite(),
+	))
+	g.w.Resize(fyne.NewSize(0, 0))
+
+	result, err := installFunc()
+	if err != nil {
+		g.showMessage(fmt.Sprintf("An error occurred while installing %s: %s", appName, err))
+		return
+	}
+
+	keyMessage := fmt.Sprintf("The Public Key has been copied to the clipboard:\n%s", result.PublicKey)
+	if err := g.installer.Platform.CopyToClipboard(result.PublicKey); err != nil {
+		keyMessage = fmt.Sprintf("Your Public Key is:\n%s\nIt could not be copied to the clipboard: %s", result.PublicKey, err)
+	}
+
+	if isUpgrade || result.Restored {
+		// The Public Key was registered with the previous install.
+		done := "installed"
+		if isUpgrade {
+			done = "upgraded"
+		}
+		g.w.SetContent(container.NewVBox(
+			widget.NewLabel(keyMessage),
+			widget.NewLabel(fmt.Sprintf("\n%s was %s successfully. No further action is necessary", appName, done)),
+			g.closeButton(),
+		))
+		return
+	}
+
+	g.w.SetContent(container.NewVBox(
+		widget.NewLabel(keyMessage),
+		widget.NewHyperlink("\nNavigate to the Network Validation Layer Nodes page on the Coiin Console.", consoleURL),
+		widget.NewLabel("Paste the Public Key into the \"Enter Public Key\" text box and click the \"Register Node\" button."),
+		widget.NewLabel(fmt.Sprintf("\n%s was installed successfully", appName)),
+		g.closeButton(),
+	))
+}
+
+func (g *GUI) copyPublicKey() {
+	publicKey, err := g.installer.CopyPublicKey()
+	if err != nil {
+		g.showMessage(fmt.Sprintf("An error occurred while copying the Public Key: %s", err))
+		return
+	}
+	g.showMessage(fmt.Sprintf("The Public Key has been copied to your clipboard: \n\n%s", publicKey))
+}
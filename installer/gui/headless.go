@@ -0,0 +1,153 @@
+package gui
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// HeadlessResult is the JSON an unattended install prints.
+type HeadlessResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// Version is the version of the installer.
+	Version string `json:"version"`
+	// Upgraded is set when the signer was already installed.
+	Upgraded      bool              `json:"upgraded"`
+	PublicKey     string            `json:"publicKey,omitempty"`
+	NewKey        bool              `json:"newKey"`
+	Restored      bool              `json:"restored"`
+	PublicKeyFile string            `json:"publicKeyFile,omitempty"`
+	DataDir       string            `json:"dataDir,omitempty"`
+	ExecPath      string            `json:"execPath,omitempty"`
+	Scheduler     string            `json:"scheduler,omitempty"`
+	Schedule      *HeadlessSchedule `json:"schedule,omitempty"`
+}
+
+// HeadlessSchedule is install.Settings in the JSON of an unattended install.
+type HeadlessSchedule struct {
+	IntervalSeconds int64 `json:"intervalSeconds"`
+	RunAtLoad       bool  `json:"runAtLoad"`
+	OnACPower       bool  `json:"onACPower"`
+	WhenIdle        bool  `json:"whenIdle"`
+}
+
+// Main runs the installer with the command-line arguments args: unattended
+// when they contain -silent, with the installer window otherwise.
+func Main(installer *Installer, args []string) {
+	flags := flag.NewFlagSet(installer.AppName, flag.ContinueOnError)
+	silent := flags.Bool("silent", false, "Install without showing a window and print the result as JSON")
+	config := flags.String("config", "", "Configuration file of the signer, a JSON object of flag names and values")
+	importKey := flags.String("import-key", "", "Encrypted backup to restore the signing key from. The passphrase is read from the "+signer.BackupPassphraseEnv+" environment variable")
+	schedule := flags.String("schedule", "", `When the signer runs, e.g. "1h,runAtLoad=false,onACPower" (defaults to the current schedule)`)
+	if err := flags.Parse(withoutProcessSerialNumber(args)); errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	if !*silent {
+		if flags.NFlag() > 0 {
+			fmt.Fprintln(os.Stderr, "-config, -import-key and -schedule are only used with -silent")
+			os.Exit(2)
+		}
+		NewGUI(installer).Run()
+		return
+	}
+
+	result := installer.InstallHeadless(*config, *importKey, *schedule)
+	if err := writeResult(os.Stdout, result); err != nil || !result.Success {
+		os.Exit(1)
+	}
+}
+
+// withoutProcessSerialNumber drops the -psn_ argument older versions of macOS
+// pass to apps opened from the Finder.
+func withoutProcessSerialNumber(args []string) []string {
+	var kept []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-psn_") {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// InstallHeadless installs or upgrades the signer without user interaction.
+// configFile replaces the configuration of the signer, backupFile restores the
+// signing key and schedule changes the current or default schedule as
+// described by install.ParseSettings. Empty arguments are ignored.
+func (i *Installer) InstallHeadless(configFile, backupFile, schedule string) *HeadlessResult {
+	result := &HeadlessResult{Version: i.Version}
+	if err := i.installHeadless(configFile, backupFile, schedule, result); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+func (i *Installer) installHeadless(configFile, backupFile, schedule string, result *HeadlessResult) error {
+	isInstalled, err := i.IsInstalled()
+	if err != nil {
+		return fmt.Errorf("failed to check if %s is installed: %w", i.AppName, err)
+	}
+	result.Upgraded = isInstalled
+
+	// Like in the window, an upgrade keeps the current schedule.
+	if current, _ := i.CurrentSettings(); current != nil {
+		i.Settings = *current
+	}
+	if schedule != "" {
+		if i.Settings, err = install.ParseSettings(schedule, i.Settings); err != nil {
+			return err
+		}
+	}
+	if configFile != "" {
+		if i.Config, err = os.ReadFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	var installed *install.Result
+	if backupFile != "" {
+		passphrase, ok := os.LookupEnv(signer.BackupPassphraseEnv)
+		if !ok {
+			return errors.New("the passphrase of the backup must be set in the " + signer.BackupPassphraseEnv + " environment variable")
+		}
+		installed, err = i.Restore(backupFile, passphrase)
+	} else {
+		installed, err = i.Install()
+	}
+	if err != nil {
+		return err
+	}
+
+	result.PublicKey = installed.PublicKey
+	result.NewKey = installed.NewKey
+	result.Restored = installed.Restored
+	result.PublicKeyFile = installed.PublicKeyFile
+	result.DataDir = installed.DataDir
+	result.ExecPath = installed.ExecPath
+	result.Scheduler = installed.Scheduler
+	result.Schedule = &HeadlessSchedule{
+		IntervalSeconds: int64(installed.Settings.Interval.Seconds()),
+		RunAtLoad:       installed.Settings.RunAtLoad,
+		OnACPower:       installed.Settings.OnACPower,
+		WhenIdle:        installed.Settings.WhenIdle,
+	}
+	return nil
+}
+
+func writeResult(w io.Writer, result *HeadlessResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
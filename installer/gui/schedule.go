@@ -0,0 +1,126 @@
+package gui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+)
+
+// intervals are the intervals offered in the schedule form.
+var intervals = []time.Duration{
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// CurrentSettings returns the settings the installed signer is scheduled
+// with, or nil if it is not installed or they cannot be read back.
+func (i *Installer) CurrentSettings() (*install.Settings, error) {
+	schedule, err := install.ScheduleStatus(i.options())
+	if schedule == nil || err != nil {
+		return nil, err
+	}
+	return schedule.Settings, nil
+}
+
+// chooseSchedule asks when the signer should run, starting from current, or
+// from the settings of the installer if current is nil. It saves the choice
+// in the installer and calls next.
+func (g *GUI) chooseSchedule(current *install.Settings, next func()) {
+	settings := g.installer.Settings
+	message := "When should the signer run?"
+	if current != nil {
+		settings = *current
+		message = fmt.Sprintf("The signer currently runs %s.\nWhen should it run?", describeSettings(settings))
+	}
+
+	options := make([]string, 0, len(intervals)+1)
+	byLabel := make(map[string]time.Duration)
+	for _, interval := range intervals {
+		label := formatInterval(interval)
+		options = append(options, label)
+		byLabel[label] = interval
+	}
+	if _, ok := byLabel[formatInterval(settings.Interval)]; !ok {
+		options = append(options, formatInterval(settings.Interval))
+		byLabel[formatInterval(settings.Interval)] = settings.Interval
+	}
+	interval := widget.NewSelect(options, nil)
+	interval.SetSelected(formatInterval(settings.Interval))
+
+	// Only the conditions the scheduler can apply are offered.
+	supported := g.installer.Platform.Supported(g.installer.options())
+	conditions := container.NewVBox()
+	runAtLoad := widget.NewCheck("Also run when I log in", nil)
+	runAtLoad.SetChecked(settings.RunAtLoad && supported.RunAtLoad)
+	if supported.RunAtLoad {
+		conditions.Add(runAtLoad)
+	}
+	onACPower := widget.NewCheck("Do not run while on battery", nil)
+	onACPower.SetChecked(settings.OnACPower && supported.OnACPower)
+	if supported.OnACPower {
+		conditions.Add(onACPower)
+	}
+	whenIdle := widget.NewCheck("Only run while the computer is idle", nil)
+	whenIdle.SetChecked(settings.WhenIdle && supported.WhenIdle)
+	if supported.WhenIdle {
+		conditions.Add(whenIdle)
+	}
+
+	g.w.SetContent(container.NewVBox(
+		widget.NewLabel(message),
+		container.NewHBox(widget.NewLabel("Run every"), interval),
+		conditions,
+		widget.NewButton("Continue", func() {
+			g.installer.Settings = install.Settings{
+				Interval:  byLabel[interval.Selected],
+				RunAtLoad: runAtLoad.Checked,
+				OnACPower: onACPower.Checked,
+				WhenIdle:  whenIdle.Checked,
+			}
+			next()
+		}),
+		g.closeButton(),
+	))
+	g.w.Resize(fyne.NewSize(0, 0))
+}
+
+// describeSettings describes settings in words, e.g. "every 30 minutes and
+// when you log in".
+func describeSettings(settings install.Settings) string {
+	description := "every " + formatInterval(settings.Interval)
+	if settings.RunAtLoad {
+		description += " and when you log in"
+	}
+	if settings.OnACPower {
+		description += ", not on battery"
+	}
+	if settings.WhenIdle {
+		description += ", only while the computer is idle"
+	}
+	return description
+}
+
+// formatInterval formats whole minutes and hours in words, e.g. "30 minutes".
+func formatInterval(interval time.Duration) string {
+	switch {
+	case interval == time.Hour:
+		return "hour"
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%d hours", interval/time.Hour)
+	case interval == time.Minute:
+		return "minute"
+	case interval%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", interval/time.Minute)
+	}
+	return interval.String()
+}
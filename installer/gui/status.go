@@ -0,0 +1,167 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/logfile"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// maxFailuresShown is how many recent failed runs the status view lists.
+const maxFailuresShown = 5
+
+// NodeStatus is what the status view shows.
+type NodeStatus struct {
+	// Schedule is nil when the signer is not registered with the scheduler.
+	Schedule *install.Schedule
+	// Signer is the status saved by the signer, nil if it has not run yet.
+	Signer    *signer.Status
+	PublicKey string
+	DataDir   string
+}
+
+// Status collects the state of the installed signer.
+func (i *Installer) Status() (*NodeStatus, error) {
+	options := i.options()
+	dataDir, err := install.DataDir(options)
+	if err != nil {
+		return nil, err
+	}
+	status := &NodeStatus{DataDir: dataDir}
+	if status.Schedule, err = install.ScheduleStatus(options); err != nil {
+		return nil, err
+	}
+	if status.PublicKey, err = i.ExistingKey(); err != nil {
+		return nil, err
+	}
+	status.Signer, err = signer.NewStore(dataDir).LoadStatus()
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return status, err
+}
+
+// RunNow asks the scheduler to run the signer now.
+func (i *Installer) RunNow() error {
+	return install.RunNow(i.options())
+}
+
+// OpenLogs opens the folder with the signer logs, or the data directory if
+// there is no such folder.
+func (i *Installer) OpenLogs() error {
+	dataDir, err := install.DataDir(i.options())
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(dataDir, logfile.Dirname)
+	if _, err := os.Stat(target); err != nil {
+		target = dataDir
+	}
+	return i.Platform.Open(target)
+}
+
+// showStatus shows the status view.
+func (g *GUI) showStatus() {
+	status, err := g.installer.Status()
+	if err != nil {
+		g.showMessage(fmt.Sprintf("An error occurred while reading the status of %s: %s", g.installer.AppName, err))
+		return
+	}
+
+	rows := container.New(layout.NewFormLayout())
+	row := func(name, value string) {
+		rows.Add(widget.NewLabelWithStyle(name, fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}))
+		rows.Add(widget.NewLabel(value))
+	}
+
+	switch {
+	case status.Schedule == nil:
+		row("Scheduled", "No, the signer is not registered with the scheduler")
+	case !status.Schedule.Enabled:
+		row("Scheduled", "Registered but disabled")
+	default:
+		row("Scheduled", "Yes")
+	}
+	if status.Schedule != nil {
+		if status.Schedule.Settings != nil {
+			row("Runs", describeSettings(*status.Schedule.Settings))
+		}
+		row("Next run", orUnknown(status.Schedule.NextRun))
+		row("Scheduler result", orUnknown(status.Schedule.LastResult))
+	}
+	row("Public Key", orUnknown(status.PublicKey))
+	row("Installer version", g.installer.Version)
+
+	failures := widget.NewLabel("")
+	if status.Signer == nil {
+		row("Last run", "The signer has not run yet")
+	} else {
+		row("Signer version", status.Signer.Version)
+		lastRun := "Unknown"
+		if run := status.Signer.LastRunRecord(); run != nil {
+			lastRun = fmt.Sprintf("%s, %s", formatTime(run.Time), run.Outcome)
+		}
+		row("Last run", lastRun)
+		if status.Signer.LastSigned != nil {
+			row("Last block signed", fmt.Sprintf("%s\n%s", status.Signer.LastSigned.IndependentBlock, formatTime(status.Signer.LastSigned.Time)))
+		} else {
+			row("Last block signed", "None yet")
+		}
+
+		text := ""
+		for _, run := range status.Signer.RecentFailures(maxFailuresShown) {
+			text += fmt.Sprintf("%s  %s\n", formatTime(run.Time), run.Error)
+		}
+		for _, alert := range status.Signer.Alerts {
+			text += fmt.Sprintf("%s  ALERT: %s\n", formatTime(alert.Time), alert.Message)
+		}
+		if text != "" {
+			failures.SetText("Recent errors:\n" + text)
+		}
+	}
+	failures.Wrapping = fyne.TextWrapWord
+
+	g.w.SetContent(container.NewVBox(
+		rows,
+		failures,
+		container.NewHBox(
+			widget.NewButton("Run now", func() {
+				if err := g.installer.RunNow(); err != nil {
+					dialog.ShowError(err, g.w)
+					return
+				}
+				dialog.ShowInformation("Run started", "The signer is running. Refresh in a moment to see the outcome.", g.w)
+			}),
+			widget.NewButton("Open logs", func() {
+				if err := g.installer.OpenLogs(); err != nil {
+					dialog.ShowError(err, g.w)
+				}
+			}),
+			widget.NewButton("Refresh", g.showStatus),
+			g.closeButton(),
+		),
+	))
+	g.w.Resize(fyne.NewSize(600, 0))
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.RFC1123)
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "Unknown"
+	}
+	return value
+}
@@ -1,24 +1,24 @@
 package main
 
 import (
-	"embed"
+	_ "embed"
+	"os"
 
-	"github.com/Coiin-Blockchain/nvl-independent-signer/installer/windows/src/coiingui"
-	"github.com/Coiin-Blockchain/nvl-independent-signer/installer/windows/src/coiininstaller"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/installer/gui"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
 )
 
 //go:embed independent-signer_windows_amd64.exe
-var independentSigner embed.FS
+var independentSigner []byte
 
-//go:embed script.bat
-var script embed.FS
-
-const appName = "Raiinmaker Network Validator"
+const (
+	appName    = "Raiinmaker Network Validator"
+	signerName = "independent-signer_windows_amd64.exe"
+)
 
 var Version = "v0.0.0"
 
 func main() {
-	coiinInstaller := coiininstaller.NewCoiinInstaller(&independentSigner, &script)
-	coiinGui := coiingui.NewGUI(appName, Version, coiinInstaller)
-	coiinGui.StartInstaller()
+	installer := gui.NewInstaller(appName, Version, signerName, independentSigner, install.DefaultPlatform())
+	gui.Main(installer, os.Args[1:])
 }
@@ -1,4 +0,0 @@
-package coiincommon
-
-// CoiinFolderName is the folder name for Coiin app
-const CoiinFolderName string = "coiin"
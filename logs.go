@@ -0,0 +1,97 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/logfile"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/systemd"
+)
+
+// LogSettings configure the log files of scheduled runs.
+type LogSettings struct {
+	Mode      string
+	MaxSizeMB int64
+	Options   logfile.Options
+}
+
+// openLogFile makes the standard logger write to the log files in dataDir as
+// well as stderr, when the settings call for it.
+func openLogFile(dataDir string, settings LogSettings) {
+	enabled, err := logfile.ParseMode(settings.Mode, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !enabled {
+		return
+	}
+
+	options := settings.Options
+	options.MaxSize = settings.MaxSizeMB << 20
+	file, err := logfile.Open(filepath.Join(dataDir, logfile.Dirname), options)
+	if err != nil {
+		// Not being able to keep a log must not stop the signer.
+		log.Printf("failed to open log file: %s", err)
+		return
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+}
+
+func runLogsCommand(dataDir string, args []string) {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	lines := flags.Int("n", 50, "Number of lines to print")
+	follow := flags.Bool("f", false, "Keep printing new lines as they are logged")
+	flags.Parse(args)
+
+	dir := filepath.Join(dataDir, logfile.Dirname)
+	tail, err := logfile.Tail(dir, *lines)
+	if err != nil {
+		log.Fatalf("failed to read logs: %s", err)
+	}
+	if len(tail) == 0 && !*follow {
+		log.Printf("No logs in %s yet\n", dir)
+		if runtime.GOOS == "linux" {
+			log.Printf("Runs scheduled with systemd log to the journal instead: journalctl -u %s, or journalctl --user-unit %s for a user timer", systemd.Name, systemd.Name)
+		}
+		return
+	}
+	for _, line := range tail {
+		fmt.Println(line)
+	}
+
+	if *follow {
+		stop := make(chan struct{})
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			close(stop)
+		}()
+		if err := logfile.Follow(dir, os.Stdout, stop); err != nil {
+			log.Fatalf("failed to follow logs: %s", err)
+		}
+	}
+}
@@ -19,415 +19,353 @@ package main
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/logfile"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/update"
 )
 
-const (
-	signingKeyFilename     = "signing-key"
-	priorBlockHashFilename = "prior-block-hash"
-)
-
-var (
-	Version = "v0.0.0"
-
-	dataDir string
-
-	signingKeyFilePath     string
-	priorBlockHashFilePath string
-
-	nvlBaseURL string
-)
-
-func init() {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		configDir, err = os.Getwd()
-		if err != nil {
-			log.Fatal("could not find working directory")
-		}
-	}
-	dataDir = filepath.Join(configDir, "coiin", "nvl", "independent-signer")
-
-	signingKeyFilePath = filepath.Join(dataDir, signingKeyFilename)
-	priorBlockHashFilePath = filepath.Join(dataDir, priorBlockHashFilename)
-
-	flag.StringVar(&nvlBaseURL, "nvlBaseURL", "https://nvl.api.coiin.ai", "Host that would be called to sign blocks to")
-	flag.Parse()
-}
-
-type NVLBlockHeader struct {
-	Type        string `json:"type"`
-	PriorBlock  string `json:"priorBlock"`
-	Timestamp   string `json:"timestamp"`
-	PublicKey   string `json:"publicKey"`
-	CoiinSupply string `json:"coiinSupply" datastore:"coiinSupply"`
-}
-
-type NVLBlockSeal struct {
-	Proofs    string `json:"proofs"`
-	Signature string `json:"signature,omitempty"`
-}
-
-type NVLBlock struct {
-	Version string          `json:"version"`
-	Header  *NVLBlockHeader `json:"header"`
-	Blocks  []string        `json:"blocks"`
-	Seal    *NVLBlockSeal   `json:"signature"`
-
-	raw string
-}
-
-func (b *NVLBlock) MarshalForSigning() ([]byte, error) {
-	blocks := b.Blocks
-	if blocks == nil {
-		blocks = make([]string, 0)
-	}
-
-	data := map[string]interface{}{
-		"header": map[string]string{
-			"type":       b.Header.Type,
-			"priorBlock": b.Header.PriorBlock,
-			"timestamp":  b.Header.Timestamp,
-			"publicKey":  b.Header.PublicKey,
-		},
-		"blocks":  blocks,
-		"version": b.Version,
-	}
-
-	if b.Header.CoiinSupply != "" {
-		data["header"].(map[string]string)["coiinSupply"] = b.Header.CoiinSupply
-	}
-
-	return json.Marshal(data)
-}
+var Version = "v0.0.0"
 
 func main() {
-	log.Printf("Starting NVL independent signer %s\n", Version)
-
-	signingKey, err := loadSigningKey()
-	if err != nil {
-		log.Fatalf("failed to load signing key: %s", err)
-	}
-
-	verifyingKey, err := loadVerifyingKey()
-	if err != nil {
-		log.Fatalf("failed to load verifying key: %s", err)
-	}
-
-	nvlBlock, err := fetchLatestNVLBlock()
+	dataDir, err := signer.DefaultDataDir()
 	if err != nil {
-		log.Fatalf("failed to fetch NVL block: %s", err)
-	} else if nvlBlock == nil {
-		return
+		log.Fatal(err)
 	}
 
-	if valid, err := verifyNVLBlock(verifyingKey, nvlBlock); err != nil {
-		log.Fatalf("error verifying NVL block: %s", err)
-	} else if !valid {
-		log.Fatalf("NVL Proxy block failed validation")
-	} else {
-		log.Println("NVL Proxy block passed verification")
+	config := signer.DefaultConfig()
+	flag.StringVar(&dataDir, "dataDir", dataDir, "Directory the signing key and state are kept in")
+	flag.Func("nvlBaseURL", "Comma separated hosts that would be called to sign blocks to, in order of preference (default "+strings.Join(config.BaseURLs, ",")+")", func(value string) error {
+		config.BaseURLs = nil
+		for _, baseURL := range strings.Split(value, ",") {
+			if baseURL = strings.TrimSpace(baseURL); baseURL != "" {
+				config.BaseURLs = append(config.BaseURLs, strings.TrimSuffix(baseURL, "/"))
+			}
+		}
+		if len(config.BaseURLs) == 0 {
+			return errors.New("no hosts given")
+		}
+		return nil
+	})
+	flag.IntVar(&config.ConsistencyQuorum, "consistencyQuorum", config.ConsistencyQuorum, "Number of hosts that must agree on the proxy key and latest block before signing (below 2 disables the check)")
+	flag.DurationVar(&config.MaxFutureSkew, "maxFutureSkew", config.MaxFutureSkew, "How far ahead of the local clock a proxy block timestamp may be (0 disables the check)")
+	flag.DurationVar(&config.MaxBlockAge, "maxBlockAge", config.MaxBlockAge, "Maximum age of a proxy block that will be signed (0 disables the check)")
+	flag.DurationVar(&config.WarnClockSkew, "warnClockSkew", config.WarnClockSkew, "Clock offset against the NVL Proxy above which a warning is logged (0 disables the warning)")
+	flag.DurationVar(&config.MaxClockSkew, "maxClockSkew", config.MaxClockSkew, "Clock offset against the NVL Proxy above which signing is refused (0 disables the check)")
+	flag.BoolVar(&config.RecoverState, "recoverState", config.RecoverState, "Resume the independent chain from the NVL Proxy when the prior block hash is missing or a posted block was not accepted")
+	flag.BoolVar(&config.CheckSupply, "checkSupply", config.CheckSupply, "Refuse to sign proxy blocks whose coiinSupply is invalid or decreased")
+	maxSupplyIncrease := flag.String("maxSupplyIncrease", "", "Maximum coiinSupply growth per hour between attested proxy blocks (empty disables the bound)")
+	flag.BoolVar(&config.VerifyChildren, "verifyChildren", config.VerifyChildren, "Fetch and verify every child block a proxy block seals before signing it")
+	flag.StringVar(&config.ChildArchiveURL, "childArchiveURL", config.ChildArchiveURL, "Host child blocks are fetched from (defaults to the NVL Proxy)")
+	flag.Func("childKeys", "Comma separated hex public keys child blocks must be signed with (needed by -verifyChildren)", func(value string) error {
+		config.ChildKeys = strings.Split(value, ",")
+		return nil
+	})
+	flag.IntVar(&config.MaxChildrenPerRun, "maxChildrenPerRun", config.MaxChildrenPerRun, "Maximum number of child blocks fetched in one run (0 disables the cap)")
+	flag.StringVar(&config.AlertURL, "alertURL", "", "URL that alerts are posted to as JSON")
+	flag.StringVar(&config.RegistrationURL, "registrationURL", config.RegistrationURL, "Host the public key is registered with (defaults to the NVL Proxy)")
+	flag.StringVar(&config.TLS.HTTPProxy, "httpProxy", config.TLS.HTTPProxy, "HTTP proxy URL requests are sent through (defaults to the HTTPS_PROXY environment variable)")
+	flag.StringVar(&config.TLS.CAFile, "caFile", config.TLS.CAFile, "PEM bundle of certificate authorities to trust in addition to the system ones")
+	flag.Func("pinSPKI", "Comma separated base64 SHA-256 hashes of NVL Proxy public keys to pin, e.g. sha256/<hash>", func(value string) error {
+		config.TLS.PinnedSPKI = strings.Split(value, ",")
+		return nil
+	})
+	flag.StringVar(&config.TLS.ClientCertFile, "clientCert", config.TLS.ClientCertFile, "PEM client certificate for mutual TLS")
+	flag.StringVar(&config.TLS.ClientKeyFile, "clientKey", config.TLS.ClientKeyFile, "PEM client key for mutual TLS")
+	logSettings := LogSettings{Mode: "auto", MaxSizeMB: logfile.DefaultOptions().MaxSize >> 20, Options: logfile.DefaultOptions()}
+	flag.StringVar(&logSettings.Mode, "logFiles", logSettings.Mode, "Write log files in the data directory: auto (when not run from a terminal or under systemd), on or off")
+	flag.Int64Var(&logSettings.MaxSizeMB, "logMaxSizeMB", logSettings.MaxSizeMB, "Size in megabytes above which the log file is rotated (0 disables size rotation)")
+	flag.DurationVar(&logSettings.Options.MaxAge, "logMaxAge", logSettings.Options.MaxAge, "Age above which the log file is rotated (0 disables age rotation)")
+	flag.IntVar(&logSettings.Options.MaxFiles, "logMaxFiles", logSettings.Options.MaxFiles, "Number of rotated log files kept (0 keeps them all)")
+	updateSettings := UpdateSettings{URL: update.DefaultURL, CheckInterval: 24 * time.Hour}
+	flag.StringVar(&updateSettings.URL, "updateURL", updateSettings.URL, "Release metadata the signer updates itself from")
+	flag.BoolVar(&updateSettings.Auto, "autoUpdate", updateSettings.Auto, "Check for a new release after each run and install it")
+	flag.DurationVar(&updateSettings.CheckInterval, "updateCheckInterval", updateSettings.CheckInterval, "Minimum time between automatic checks for a new release")
+	flag.Parse()
+	if err := applyConfigFile(filepath.Join(dataDir, signer.ConfigFilename)); err != nil {
+		log.Fatalf("failed to load configuration: %s", err)
 	}
+	config.SignerVersion = Version
 
-	priorBlockHash, err := loadPriorBlockHash()
-	if err != nil {
-		log.Fatalf("failed to load prior block hash %s", err)
+	if *maxSupplyIncrease != "" {
+		bound, err := signer.ParseSupply(*maxSupplyIncrease)
+		if err != nil {
+			log.Fatalf("invalid -maxSupplyIncrease: %s", err)
+		}
+		config.MaxSupplyIncreasePerHour = bound
 	}
 
-	indNVLBlock := createIndependentNVLBlock(signingKey, nvlBlock, priorBlockHash)
-
-	hash, sig, err := signIndependentNVLBlock(signingKey, indNVLBlock)
+	engine, err := signer.NewEngine(config, signer.NewStore(dataDir))
 	if err != nil {
-		log.Fatalf("failed to sign independent block: %s", err)
+		log.Fatal(err)
 	}
-	indNVLBlock.Seal.Proofs = hash
-	indNVLBlock.Seal.Signature = sig
+	ctx := context.Background()
 
-	if err := postIndependentNVLBlock(indNVLBlock); err != nil {
-		log.Fatalf("failed to post independent block to NVL proxy: %s", err)
-	}
-
-	if err := savePriorBlockHash(indNVLBlock.Seal.Proofs); err != nil {
-		log.Fatalf("failed to save prior block hash: %s", err)
-	}
-
-	log.Println("Complete!")
-}
-
-func loadSigningKey() (*ecdsa.PrivateKey, error) {
-	log.Println("Loading signing key")
-	if _, err := os.Stat(signingKeyFilePath); err != nil {
-		log.Println("Signing key not found")
-		if err := generateSigningKey(); err != nil {
-			return nil, err
+	switch flag.Arg(0) {
+	case "":
+		openLogFile(dataDir, logSettings)
+		run(ctx, engine)
+		if updateSettings.Auto {
+			autoUpdate(ctx, engine, updateSettings)
 		}
+	case "version":
+		fmt.Println(Version)
+	case "update":
+		runUpdateCommand(ctx, engine, updateSettings, flag.Args()[1:])
+	case "status":
+		if err := printStatus(ctx, engine); err != nil {
+			log.Fatalf("failed to read status: %s", err)
+		}
+	case "state":
+		runStateCommand(ctx, engine, flag.Arg(1))
+	case "guard":
+		runGuardCommand(engine, flag.Arg(1), flag.Arg(2))
+	case "replay":
+		runReplayCommand(engine.Store, flag.Arg(1))
+	case "install":
+		runInstallCommand(dataDir, flag.Args()[1:])
+	case "uninstall":
+		runUninstallCommand(dataDir, flag.Args()[1:])
+	case "backup":
+		runBackupCommand(ctx, engine, flag.Arg(1), flag.Arg(2))
+	case "logs":
+		runLogsCommand(dataDir, flag.Args()[1:])
+	case "blocks":
+		runBlocksCommand(engine.Store, flag.Arg(1), flag.Arg(2))
+	case "register":
+		runRegisterCommand(ctx, engine, flag.Args()[1:])
+	case "registration":
+		runRegistrationCommand(ctx, engine, flag.Arg(1))
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
 	}
-
-	fileData, err := os.ReadFile(signingKeyFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	signingKey, err := crypto.HexToECDSA(strings.TrimSpace(string(fileData)))
-
-	publicKey := signingKey.Public().(*ecdsa.PublicKey)
-	log.Printf("Public Key: %s\n", strings.ToLower(hex.EncodeToString(crypto.FromECDSAPub(publicKey))))
-
-	return signingKey, err
 }
 
-func generateSigningKey() error {
-	log.Println("Generating signing key")
-	// Ensure the data directory exists
-	if err := os.MkdirAll(dataDir, 0700); err != nil {
-		return err
-	}
-
-	signingKey, err := crypto.GenerateKey()
-	if err != nil {
+// applyConfigFile sets flags from the JSON object in path, whose keys are flag
+// names. Flags given on the command line take precedence.
+func applyConfigFile(path string) error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(signingKeyFilePath, os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		return err
+	values := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
 	}
-	defer mustClose(file)
 
-	_, err = file.WriteString(strings.ToLower(hex.EncodeToString(crypto.FromECDSA(signingKey))))
-	if err != nil {
-		return err
+	for name, value := range values {
+		if flag.Lookup(name) == nil || name == "dataDir" {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		var text string
+		switch value := value.(type) {
+		case string:
+			text = value
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			text = strings.Join(items, ",")
+		default:
+			text = fmt.Sprint(value)
+		}
+		if err := flag.Set(name, text); err != nil {
+			return fmt.Errorf("%s: invalid %s: %w", path, name, err)
+		}
 	}
-
-	log.Println("New signing key generated")
 	return nil
 }
 
-func loadVerifyingKey() ([]byte, error) {
-	log.Println("Loading NVL Proxy verifying key")
-
-	resp, err := http.Get(nvlBaseURL + "/api/v1/status")
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("NVL returned non 200 status code: Status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	defer mustClose(resp.Body)
+func run(ctx context.Context, engine *signer.Engine) {
+	log.Printf("Starting NVL independent signer %s\n", Version)
 
-	status := &struct {
-		PublicKey string `json:"publicKey"`
-	}{}
-	if err := json.Unmarshal(body, status); err != nil {
-		return nil, err
+	if _, err := engine.RunOnce(ctx); errors.Is(err, signer.ErrAlreadySigned) {
+		log.Printf("Nothing signed: %s\n", err)
+		return
+	} else if err != nil {
+		log.Fatal(err)
 	}
 
-	return hexutil.Decode("0x" + status.PublicKey)
+	log.Println("Complete!")
 }
 
-func fetchLatestNVLBlock() (*NVLBlock, error) {
-	log.Println("Fetching latest NVL Proxy block")
-
-	blockHash, err := fetchLatestNVLBlockHash()
-	if err != nil {
-		return nil, err
+func printStatus(ctx context.Context, engine *signer.Engine) error {
+	printSchedule()
+	if err := printLastRun(engine.Store); err != nil {
+		return err
 	}
 
-	resp, err := http.Get(nvlBaseURL + "/api/v1/blocks/" + blockHash + "?raw=true")
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("NVL returned non 200 status code: Status %d", resp.StatusCode)
+	// The health saved by the last run may be stale, so ask every endpoint.
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	for _, endpoint := range engine.Client.CheckHealth(ctx) {
+		health := "healthy"
+		if !endpoint.Healthy {
+			health = "unavailable: " + endpoint.LastError
+		}
+		fmt.Printf("Endpoint:       %s %s\n", endpoint.URL, health)
 	}
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func printLastRun(store *signer.Store) error {
+	status, err := store.LoadStatus()
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("The independent signer has not run yet")
+		return nil
+	} else if err != nil {
+		return err
 	}
-	defer mustClose(resp.Body)
 
-	block := new(NVLBlock)
-	if err := json.Unmarshal(body, block); err != nil {
-		return nil, err
+	fmt.Printf("Signer version: %s\n", status.Version)
+	fmt.Printf("Last run:       %s\n", status.LastRun.Local().Format(time.RFC1123))
+	if run := status.LastRunRecord(); run != nil {
+		fmt.Printf("Last outcome:   %s\n", describeRun(run))
 	}
-
-	block.raw = string(body)
-
-	log.Printf("Latest NVL Proxy Block hash: %s\n", block.Seal.Proofs)
-
-	return block, nil
-}
-
-func fetchLatestNVLBlockHash() (string, error) {
-	resp, err := http.Get(nvlBaseURL + "/api/v1/blocks?size=1")
-	if err != nil {
-		return "", err
+	if status.LastSigned != nil {
+		fmt.Printf("Last signed:    %s at %s\n", status.LastSigned.IndependentBlock, status.LastSigned.Time.Local().Format(time.RFC1123))
 	}
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("NVL returned non 200 status code: Status %d", resp.StatusCode)
+	if status.ClockOffsetSeconds != nil {
+		fmt.Printf("Clock offset:   %.3fs\n", *status.ClockOffsetSeconds)
+	} else {
+		fmt.Println("Clock offset:   unknown")
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if status.LastUpdateCheck != nil {
+		fmt.Printf("Update check:   %s\n", status.LastUpdateCheck.Local().Format(time.RFC1123))
 	}
-	defer mustClose(resp.Body)
-
-	blocks := &struct {
-		Blocks []struct {
-			Hash string `json:"hash"`
+	if failures := status.RecentFailures(5); len(failures) > 0 {
+		fmt.Println("Recent failures:")
+		for _, run := range failures {
+			fmt.Printf("  %s  %s\n", run.Time.Local().Format(time.RFC1123), run.Error)
 		}
-	}{}
-	if err := json.Unmarshal(body, blocks); err != nil {
-		return "", err
 	}
-
-	if len(blocks.Blocks) != 1 {
-		log.Println("NVL did not return any blocks to sign")
-		return "", nil
+	if len(status.Alerts) > 0 {
+		fmt.Println("Alerts:")
+		for _, alert := range status.Alerts {
+			fmt.Printf("  %s  %s\n", alert.Time.Local().Format(time.RFC1123), alert.Message)
+		}
 	}
-
-	return blocks.Blocks[0].Hash, nil
+	return nil
 }
 
-func verifyNVLBlock(publicKey []byte, block *NVLBlock) (bool, error) {
-	sig, err := hexutil.Decode("0x" + block.Seal.Signature)
-	if err != nil {
-		return false, err
-	}
-	data, err := block.MarshalForSigning()
-	if err != nil {
-		return false, err
+// describeRun summarizes a run journal entry on one line.
+func describeRun(run *signer.RunRecord) string {
+	if run.Error != "" {
+		return run.Outcome + ": " + run.Error
 	}
-	hash := crypto.Keccak256Hash(data)
-	return crypto.VerifySignature(publicKey, hash.Bytes(), sig[:len(sig)-1]), nil
+	return run.Outcome
 }
 
-func loadPriorBlockHash() (string, error) {
-	log.Println("Loading prior block hash")
-	if _, err := os.Stat(priorBlockHashFilePath); err != nil {
-		log.Println("No prior block hash, must be first time executed")
-		return "", nil
+func runStateCommand(ctx context.Context, engine *signer.Engine, action string) {
+	if action != "recover" {
+		log.Fatalf("usage: independent-signer state recover")
 	}
 
-	fileData, err := os.ReadFile(priorBlockHashFilePath)
-	if err != nil {
-		return "", err
+	if _, err := engine.Recover(ctx); err != nil {
+		log.Fatal(err)
 	}
-
-	return strings.TrimSpace(string(fileData)), nil
 }
 
-func createIndependentNVLBlock(signingKey *ecdsa.PrivateKey, block *NVLBlock, priorHash string) *NVLBlock {
-	log.Println("Creating independent NVL block")
-
-	publicKey := strings.ToLower(hex.EncodeToString(crypto.FromECDSAPub(signingKey.Public().(*ecdsa.PublicKey))))
-	return &NVLBlock{
-		Version: "1",
-		Header: &NVLBlockHeader{
-			Type:        "INDEPENDENT",
-			PriorBlock:  priorHash,
-			Timestamp:   fmt.Sprintf("%d", time.Now().Unix()),
-			PublicKey:   publicKey,
-			CoiinSupply: block.Header.CoiinSupply,
-		},
-		Blocks: []string{block.Seal.Proofs},
-		Seal:   &NVLBlockSeal{},
+func runGuardCommand(engine *signer.Engine, action, path string) {
+	if path == "" {
+		log.Fatalf("usage: independent-signer guard export|import <file>")
 	}
-}
 
-func signIndependentNVLBlock(signingKey *ecdsa.PrivateKey, block *NVLBlock) (string, string, error) {
-	data, err := block.MarshalForSigning()
-	if err != nil {
-		return "", "", err
-	}
-	hash := crypto.Keccak256Hash(data)
-	signature, err := crypto.Sign(hash.Bytes(), signingKey)
-	if err != nil {
-		return "", "", err
+	switch action {
+	case "export":
+		if err := engine.ExportGuard(path); err != nil {
+			log.Fatalf("failed to export signing guard: %s", err)
+		}
+		log.Printf("Signing guard exported to %s\n", path)
+	case "import":
+		added, err := engine.ImportGuard(path)
+		if err != nil {
+			log.Fatalf("failed to import signing guard: %s", err)
+		}
+		log.Printf("Imported %d attestations from %s\n", added, path)
+	default:
+		log.Fatalf("unknown guard command %q", action)
 	}
-
-	hashStr := fmt.Sprintf("%064x", hash.Bytes())
-	sigStr := fmt.Sprintf("%0130x", signature)
-	log.Println("New independent NVL block signed!")
-	log.Printf("Hash: %s\n", hashStr)
-	log.Printf("Signature: %s\n", sigStr)
-
-	return hashStr, sigStr, nil
 }
 
-func postIndependentNVLBlock(block *NVLBlock) error {
-	log.Println("Posting new block to NVL Proxy")
-
-	body := struct {
-		Version                  string    `json:"version"`
-		Block                    *NVLBlock `json:"block"`
-		IndependentSignerVersion string    `json:"independentSignerVersion"`
-	}{
-		Version:                  "1",
-		Block:                    block,
-		IndependentSignerVersion: Version,
+func runReplayCommand(store *signer.Store, hash string) {
+	if hash == "" {
+		log.Fatalf("usage: independent-signer replay <independent block hash>")
 	}
 
-	jsonBody, err := json.Marshal(body)
+	attestation, err := store.LoadAttestation(hash)
 	if err != nil {
-		return err
+		log.Fatalf("failed to load attestation: %s", err)
 	}
-
-	resp, err := http.Post(
-		nvlBaseURL+"/api/v1/independent/enqueue",
-		"application/json",
-		bytes.NewBuffer(jsonBody),
-	)
-	if err != nil {
-		return err
+	if err := attestation.Replay(); err != nil {
+		log.Fatalf("Attestation %s failed replay: %s", hash, err)
 	}
+	log.Printf("Attestation %s replayed successfully over NVL Proxy block %s\n", hash, attestation.IndependentBlock.Blocks[0])
+}
 
-	log.Printf("NVL Proxy resp code: %d\n", resp.StatusCode)
-
-	if resp.StatusCode > 299 {
-		respBody, err := io.ReadAll(resp.Body)
+func runBlocksCommand(store *signer.Store, action, path string) {
+	switch action {
+	case "list":
+		blocks, err := store.CachedBlocks()
 		if err != nil {
-			return err
+			log.Fatalf("failed to read block cache: %s", err)
 		}
-		defer mustClose(resp.Body)
-		log.Println(string(respBody))
-	}
-
-	return nil
-}
-
-func savePriorBlockHash(hash string) error {
-	log.Printf("Saving prior block hash: %s\n", hash)
-	return os.WriteFile(priorBlockHashFilePath, []byte(hash), 0600)
-}
-
-type Closer interface {
-	Close() error
-}
-
-func mustClose(f Closer) {
-	if err := f.Close(); err != nil {
-		log.Fatalf("Close() returned error: %s", err)
+		for _, block := range blocks {
+			result := "verified"
+			if !block.Verified {
+				result = "invalid: " + block.Error
+			}
+			fmt.Printf("%s  %s  %s\n", block.Hash, block.FetchedAt.Local().Format(time.RFC1123), result)
+		}
+	case "verify":
+		report, err := store.CheckBlockCache()
+		if err != nil {
+			log.Fatalf("failed to read block cache: %s", err)
+		}
+		for hash, reason := range report.Invalid {
+			log.Printf("Cached block %s failed verification: %s\n", hash, reason)
+		}
+		for _, hash := range report.Gaps {
+			log.Printf("The block before cached block %s is not cached\n", hash)
+		}
+		if len(report.Invalid) > 0 {
+			log.Fatalf("%d of %d cached blocks failed verification", len(report.Invalid), report.Blocks)
+		}
+		log.Printf("All %d cached blocks passed verification\n", report.Blocks)
+	case "export":
+		if path == "" {
+			log.Fatalf("usage: independent-signer blocks export <directory>")
+		}
+		count, err := store.ExportMirror(path)
+		if err != nil {
+			log.Fatalf("failed to export block mirror: %s", err)
+		}
+		log.Printf("Exported %d blocks to %s\n", count, path)
+	default:
+		log.Fatalf("usage: independent-signer blocks list|verify|export <directory>")
 	}
 }
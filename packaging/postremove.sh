@@ -0,0 +1,8 @@
+#!/bin/sh
+set -e
+
+# The signing key in /var/lib/independent-signer is kept on purpose, so that
+# reinstalling the package keeps the registered public key.
+if [ -d /run/systemd/system ]; then
+    systemctl daemon-reload || true
+fi
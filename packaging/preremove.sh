@@ -0,0 +1,11 @@
+#!/bin/sh
+set -e
+
+# Only stop the timer when the package is removed, not when it is upgraded.
+case "$1" in
+    remove|0)
+        if [ -d /run/systemd/system ]; then
+            systemctl disable --now independent-signer.timer || true
+        fi
+        ;;
+esac
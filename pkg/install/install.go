@@ -0,0 +1,342 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package install sets the independent signer up to run on a schedule: it
+// writes the executable, generates the signing key, saves the public-key file
+// and registers the signer with the scheduler of the operating system
+// (launchd on macOS, the Task Scheduler on Windows and systemd on Linux).
+//
+// Installers call Install and get the public key back, instead of running a
+// script and parsing its output.
+//
+// The scheduler of each operating system is a Platform. Options.Platform
+// replaces it, e.g. with the fake installtest.Platform when testing an
+// installer.
+package install
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/systemd"
+)
+
+// DefaultInterval is how often the scheduler runs the signer.
+const DefaultInterval = 30 * time.Minute
+
+// Options describe an install.
+type Options struct {
+	// DataDir is where the signing key is kept. It defaults to
+	// signer.DefaultDataDir.
+	DataDir string
+	// Executable, if set, is written to ExecPath before the signer is
+	// registered. Installers that embed the signer use it.
+	Executable []byte
+	// ExecPath is the signer executable the scheduler runs. It defaults to
+	// ExecutableName in DataDir when Executable is set, and to the running
+	// executable otherwise.
+	ExecPath string
+	// ExecutableName is the file name the embedded Executable is written to.
+	ExecutableName string
+	// Settings are when the signer runs.
+	Settings Settings
+	// System installs a system wide service instead of one for the current
+	// user. It is only supported on Linux.
+	System bool
+	// Platform schedules the signer. It defaults to DefaultPlatform.
+	Platform Platform
+	// RestoreFrom, if set, is a backup restored into DataDir before the signer
+	// is registered, with RestorePassphrase. The first run then resumes the
+	// independent chain from the NVL Proxy.
+	RestoreFrom       string
+	RestorePassphrase string
+	// Config, if set, replaces the configuration file in DataDir before the
+	// signer is registered. It must be a JSON object of signer flags.
+	Config []byte
+	// RemoveData makes Uninstall delete DataDir, and with it the signing key.
+	RemoveData bool
+}
+
+// Result describes a completed install.
+type Result struct {
+	// PublicKey is the hex encoded public key to register in the Coiin
+	// Console.
+	PublicKey string
+	// NewKey is set when the signing key was generated by this install.
+	NewKey bool
+	// Restored is set when the signing key was restored from a backup.
+	Restored bool
+	// PublicKeyFile is the file the public key was saved to.
+	PublicKeyFile string
+	DataDir       string
+	ExecPath      string
+	// Scheduler describes how the signer is run, e.g. "launchd".
+	Scheduler string
+	// Settings are when the scheduler runs the signer.
+	Settings Settings
+}
+
+// Install sets the signer up as described by options.
+func Install(options Options) (*Result, error) {
+	options, err := withDefaults(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(options); err != nil {
+		return nil, err
+	}
+	if options.Config != nil {
+		if err := checkConfig(options); err != nil {
+			return nil, err
+		}
+	}
+	if options.Executable != nil {
+		if err := os.MkdirAll(filepath.Dir(options.ExecPath), 0755); err != nil {
+			return nil, err
+		}
+		if err := signer.WriteFileAtomic(options.ExecPath, options.Executable, 0755); err != nil {
+			return nil, fmt.Errorf("failed to write the signer executable: %w", err)
+		}
+	}
+
+	result := &Result{DataDir: options.DataDir, ExecPath: options.ExecPath, Scheduler: options.Platform.Name(options), Settings: options.Settings}
+	if options.RestoreFrom != "" {
+		if options.System {
+			// The system service owns its data directory, which does not
+			// exist before the service first starts.
+			return nil, errors.New("backups can only be restored into installs for the current user")
+		}
+		if _, err := signer.NewStore(options.DataDir).RestoreBackup(options.RestoreFrom, options.RestorePassphrase); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", options.RestoreFrom, err)
+		}
+		result.Restored = true
+	}
+	if options.Config != nil {
+		if err := os.MkdirAll(options.DataDir, 0700); err != nil {
+			return nil, err
+		}
+		if err := signer.WriteFileAtomic(filepath.Join(options.DataDir, signer.ConfigFilename), options.Config, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write the configuration file: %w", err)
+		}
+	}
+	if options.System {
+		// The system service owns its data directory, so it generates the
+		// key itself when it is first started by register.
+		if err := options.Platform.Register(options); err != nil {
+			return nil, err
+		}
+		if err := savePublicKey(options.DataDir, false, result); err != nil {
+			return nil, fmt.Errorf("%w. Check the service logs with journalctl -u %s", err, systemd.Name)
+		}
+		return result, nil
+	}
+
+	if err := savePublicKey(options.DataDir, true, result); err != nil {
+		return nil, err
+	}
+	if err := options.Platform.Register(options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Uninstall removes the signer from the scheduler. The data directory, and
+// with it the signing key, is kept unless options.RemoveData is set.
+func Uninstall(options Options) error {
+	options, err := withDefaults(options)
+	if err != nil {
+		return err
+	}
+	if err := options.Platform.Unregister(options); err != nil {
+		return err
+	}
+	if options.RemoveData {
+		return os.RemoveAll(options.DataDir)
+	}
+	return nil
+}
+
+// Installed reports whether the signer is registered with the scheduler.
+func Installed(options Options) (bool, error) {
+	options, err := withDefaults(options)
+	if err != nil {
+		return false, err
+	}
+	schedule, err := options.Platform.Schedule(options)
+	return schedule != nil, err
+}
+
+// ScheduleStatus returns the state of the signer in the scheduler, or nil if
+// it is not registered.
+func ScheduleStatus(options Options) (*Schedule, error) {
+	options, err := withDefaults(options)
+	if err != nil {
+		return nil, err
+	}
+	return options.Platform.Schedule(options)
+}
+
+// RunNow asks the scheduler to run the signer now.
+func RunNow(options Options) error {
+	options, err := withDefaults(options)
+	if err != nil {
+		return err
+	}
+	return options.Platform.RunNow(options)
+}
+
+// DataDir returns the data directory of options, applying the default.
+func DataDir(options Options) (string, error) {
+	options, err := withDefaults(options)
+	return options.DataDir, err
+}
+
+// ExistingKey returns the public key of the signing key in the data directory
+// of options, or an empty string if no key has been generated yet.
+func ExistingKey(options Options) (string, error) {
+	options, err := withDefaults(options)
+	if err != nil {
+		return "", err
+	}
+	store := signer.NewStore(options.DataDir)
+	if exists, err := store.HasSigningKey(); err != nil || !exists {
+		return "", err
+	}
+	return PublicKey(options.DataDir)
+}
+
+// ExportBackup writes an encrypted backup of the signing key and state in the
+// data directory of options to path.
+func ExportBackup(options Options, path, passphrase string) (string, error) {
+	options, err := withDefaults(options)
+	if err != nil {
+		return "", err
+	}
+	return signer.NewStore(options.DataDir).ExportBackup(path, passphrase)
+}
+
+// PublicKey returns the public key of the signing key in dataDir, or of the
+// default data directory if dataDir is empty.
+func PublicKey(dataDir string) (string, error) {
+	if dataDir == "" {
+		var err error
+		if dataDir, err = signer.DefaultDataDir(); err != nil {
+			return "", err
+		}
+	}
+	signingKey, err := signer.NewStore(dataDir).LoadSigningKey()
+	if err != nil {
+		return "", err
+	}
+	return signer.PublicKeyHex(signingKey), nil
+}
+
+func withDefaults(options Options) (Options, error) {
+	if options.Platform == nil {
+		options.Platform = DefaultPlatform()
+	}
+	if options.DataDir == "" {
+		dataDir, err := options.Platform.DefaultDataDir(options)
+		if err != nil {
+			return options, err
+		}
+		options.DataDir = dataDir
+	}
+	// The scheduler does not run the signer from the current directory.
+	dataDir, err := filepath.Abs(options.DataDir)
+	if err != nil {
+		return options, err
+	}
+	options.DataDir = dataDir
+	if options.ExecPath == "" {
+		if options.Executable != nil {
+			if options.ExecutableName == "" {
+				return options, errors.New("an embedded executable needs a name")
+			}
+			options.ExecPath = filepath.Join(options.DataDir, options.ExecutableName)
+		} else {
+			path, err := os.Executable()
+			if err != nil {
+				return options, fmt.Errorf("failed to find the signer executable: %w", err)
+			}
+			options.ExecPath = path
+		}
+	}
+	if options.Settings.Interval <= 0 {
+		options.Settings.Interval = DefaultInterval
+	}
+	return options, nil
+}
+
+// checkConfig checks that options.Config can be written to the configuration
+// file of the data directory.
+func checkConfig(options Options) error {
+	if options.System {
+		// Like backups, the configuration of the system service is written
+		// once the service has created its data directory.
+		return errors.New("a configuration file can only be written into installs for the current user")
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(options.Config, &config); err != nil || config == nil {
+		return errors.New("the configuration file must hold a JSON object of flag names and values")
+	}
+	return nil
+}
+
+// savePublicKey loads the signing key from dataDir, generating it if needed
+// and allowed, and saves the public-key file.
+func savePublicKey(dataDir string, generate bool, result *Result) error {
+	store := signer.NewStore(dataDir)
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+	lock, err := store.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	exists, err := store.HasSigningKey()
+	if err != nil {
+		return err
+	}
+	var signingKey *ecdsa.PrivateKey
+	if exists {
+		signingKey, err = store.LoadSigningKey()
+	} else if generate {
+		signingKey, err = store.GenerateSigningKey()
+		result.NewKey = true
+	} else {
+		err = errors.New("no signing key was generated")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load signing key: %w", err)
+	}
+
+	if err := store.SavePublicKey(signingKey); err != nil {
+		return fmt.Errorf("failed to save public key: %w", err)
+	}
+	result.PublicKey = signer.PublicKeyHex(signingKey)
+	result.PublicKeyFile = filepath.Join(dataDir, signer.PublicKeyFilename)
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package install_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install/installtest"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+func testOptions(t *testing.T) (install.Options, *installtest.Platform) {
+	t.Helper()
+	platform := &installtest.Platform{DataDir: filepath.Join(t.TempDir(), "data")}
+	return install.Options{
+		Executable:     []byte("signer executable"),
+		ExecutableName: "independent-signer",
+		Platform:       platform,
+	}, platform
+}
+
+func TestInstall(t *testing.T) {
+	options, platform := testOptions(t)
+	options.Settings = install.Settings{Interval: time.Hour, OnACPower: true}
+
+	result, err := install.Install(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.NewKey || result.Restored || result.Scheduler != "fake scheduler" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.DataDir != platform.DataDir || result.ExecPath != filepath.Join(platform.DataDir, "independent-signer") {
+		t.Errorf("installed into %s and %s", result.DataDir, result.ExecPath)
+	}
+	if executable, err := os.ReadFile(result.ExecPath); err != nil || !bytes.Equal(executable, options.Executable) {
+		t.Errorf("executable is %q, %v", executable, err)
+	}
+	if publicKey, err := os.ReadFile(result.PublicKeyFile); err != nil || strings.TrimSpace(string(publicKey)) != result.PublicKey {
+		t.Errorf("public-key file holds %q, %v, want %s", publicKey, err, result.PublicKey)
+	}
+	registered := platform.Registered()
+	if registered == nil || registered.ExecPath != result.ExecPath || registered.Settings != options.Settings {
+		t.Fatalf("registered %+v", registered)
+	}
+	if command := platform.Command(); !reflect.DeepEqual(command, []string{result.ExecPath, "-dataDir", result.DataDir}) {
+		t.Errorf("registered command %q", command)
+	}
+
+	// Upgrades keep the signing key.
+	upgrade, err := install.Install(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgrade.NewKey || upgrade.PublicKey != result.PublicKey {
+		t.Errorf("upgrade replaced the key: %+v", upgrade)
+	}
+}
+
+func TestInstallDefaults(t *testing.T) {
+	options, platform := testOptions(t)
+	if _, err := install.Install(options); err != nil {
+		t.Fatal(err)
+	}
+	if settings := platform.Registered().Settings; settings.Interval != install.DefaultInterval {
+		t.Errorf("registered with interval %s, want %s", settings.Interval, install.DefaultInterval)
+	}
+}
+
+func TestInstallDataDir(t *testing.T) {
+	// The scheduled runs must use the data directory the key was generated
+	// in, not the default one.
+	options, platform := testOptions(t)
+	options.DataDir = filepath.Join(t.TempDir(), "elsewhere")
+	result, err := install.Install(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DataDir != options.DataDir || filepath.Dir(result.PublicKeyFile) != options.DataDir {
+		t.Errorf("installed into %s", result.DataDir)
+	}
+	if command := platform.Command(); !reflect.DeepEqual(command, []string{result.ExecPath, "-dataDir", options.DataDir}) {
+		t.Errorf("registered command %q, want it to carry -dataDir %s", command, options.DataDir)
+	}
+
+	// A relative data directory is made absolute, as the scheduler does not
+	// run the signer from the current directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	options.DataDir = "relative"
+	if _, err := install.Install(options); err != nil {
+		t.Fatal(err)
+	}
+	if command := platform.Command(); len(command) != 3 || !filepath.IsAbs(command[2]) {
+		t.Errorf("registered command %q, want an absolute data directory", command)
+	}
+}
+
+func TestInstallRefusesBeforeWriting(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*install.Options, *installtest.Platform)
+	}{
+		{"unsupported condition", func(options *install.Options, platform *installtest.Platform) {
+			platform.Unsupported.WhenIdle = true
+			options.Settings.WhenIdle = true
+		}},
+		{"invalid config", func(options *install.Options, platform *installtest.Platform) {
+			options.Config = []byte(`["not", "an", "object"]`)
+		}},
+	}
+	for _, test := range tests {
+		options, platform := testOptions(t)
+		test.change(&options, platform)
+		if _, err := install.Install(options); err == nil {
+			t.Errorf("%s: installed", test.name)
+		}
+		if _, err := os.Stat(platform.DataDir); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: the data directory was created", test.name)
+		}
+		if platform.Registered() != nil {
+			t.Errorf("%s: registered", test.name)
+		}
+	}
+}
+
+func TestInstallRegisterError(t *testing.T) {
+	options, platform := testOptions(t)
+	platform.RegisterErr = errors.New("scheduler unavailable")
+	if _, err := install.Install(options); !errors.Is(err, platform.RegisterErr) {
+		t.Errorf("got %v, want the error of the scheduler", err)
+	}
+}
+
+func TestInstallConfigAndRestore(t *testing.T) {
+	source := signer.NewStore(t.TempDir())
+	signingKey, err := source.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(t.TempDir(), "backup.json")
+	if _, err := source.ExportBackup(backup, "a long passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	options, platform := testOptions(t)
+	options.RestoreFrom, options.RestorePassphrase = backup, "a long passphrase"
+	options.Config = []byte(`{"verifyChildren": true}`)
+	result, err := install.Install(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Restored || result.NewKey || result.PublicKey != signer.PublicKeyHex(signingKey) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	config, err := os.ReadFile(filepath.Join(platform.DataDir, signer.ConfigFilename))
+	if err != nil || !bytes.Equal(config, options.Config) {
+		t.Errorf("config file holds %q, %v", config, err)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	options, platform := testOptions(t)
+	result, err := install.Install(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := install.Uninstall(options); err != nil {
+		t.Fatal(err)
+	}
+	if platform.Registered() != nil {
+		t.Error("still registered after uninstall")
+	}
+	if publicKey, err := install.ExistingKey(options); err != nil || publicKey != result.PublicKey {
+		t.Errorf("key after uninstall is %q, %v, want it kept", publicKey, err)
+	}
+
+	options.RemoveData = true
+	if err := install.Uninstall(options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(platform.DataDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("data directory kept with RemoveData: %v", err)
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+	options, _ := testOptions(t)
+	if schedule, err := install.ScheduleStatus(options); err != nil || schedule != nil {
+		t.Errorf("before install: got %+v, %v", schedule, err)
+	}
+	if installed, err := install.Installed(options); err != nil || installed {
+		t.Errorf("before install: installed %t, %v", installed, err)
+	}
+
+	options.Settings = install.Settings{Interval: 15 * time.Minute, RunAtLoad: true}
+	if _, err := install.Install(options); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := install.ScheduleStatus(options)
+	if err != nil || schedule == nil || !schedule.Enabled {
+		t.Fatalf("after install: got %+v, %v", schedule, err)
+	}
+	if schedule.Settings == nil || *schedule.Settings != options.Settings {
+		t.Errorf("schedule settings are %+v, want %+v", schedule.Settings, options.Settings)
+	}
+	if installed, err := install.Installed(options); err != nil || !installed {
+		t.Errorf("after install: installed %t, %v", installed, err)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	base := install.Settings{Interval: 30 * time.Minute, RunAtLoad: true}
+	tests := []struct {
+		text string
+		want install.Settings
+		ok   bool
+	}{
+		{"", base, true},
+		{"1h", install.Settings{Interval: time.Hour, RunAtLoad: true}, true},
+		{"1h, runAtLoad=false, onACPower", install.Settings{Interval: time.Hour, OnACPower: true}, true},
+		{"whenIdle=true,onACPower=0", install.Settings{Interval: 30 * time.Minute, RunAtLoad: true, WhenIdle: true}, true},
+		{"0s", base, false},
+		{"-5m", base, false},
+		{"onACPower=maybe", base, false},
+		{"hourly", base, false},
+	}
+	for _, test := range tests {
+		got, err := install.ParseSettings(test.text, base)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseSettings(%q) = %+v, %v, want %+v", test.text, got, err, test.want)
+		}
+	}
+}
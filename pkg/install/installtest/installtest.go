@@ -0,0 +1,164 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package installtest provides a fake install.Platform, so that installs and
+// installers can be tested without touching the scheduler of the operating
+// system.
+package installtest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/install"
+)
+
+var _ install.Platform = (*Platform)(nil)
+
+// Platform is an install.Platform that keeps its registration in memory. Its
+// zero value supports every condition of install.Settings.
+type Platform struct {
+	mu sync.Mutex
+
+	// DataDir is the default data directory.
+	DataDir string
+	// Unsupported are the conditions of install.Settings the platform
+	// reports it cannot apply.
+	Unsupported install.Settings
+	// RegisterErr, if set, is returned by Register.
+	RegisterErr error
+
+	registered *install.Options
+	command    []string
+	runs       int
+	clipboard  string
+	opened     []string
+}
+
+// Registered returns the options the signer is registered with, or nil if it
+// is not registered.
+func (p *Platform) Registered() *install.Options {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.registered == nil {
+		return nil
+	}
+	options := *p.registered
+	return &options
+}
+
+// Command returns the command line a scheduler would run, or nil if the
+// signer is not registered.
+func (p *Platform) Command() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.command...)
+}
+
+// Runs returns how often RunNow was called.
+func (p *Platform) Runs() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.runs
+}
+
+// Clipboard returns the text last copied to the clipboard.
+func (p *Platform) Clipboard() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clipboard
+}
+
+// Opened returns the targets passed to Open.
+func (p *Platform) Opened() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.opened...)
+}
+
+func (p *Platform) Name(options install.Options) string {
+	return "fake scheduler"
+}
+
+func (p *Platform) DefaultDataDir(options install.Options) (string, error) {
+	if p.DataDir == "" {
+		return "", errors.New("the fake platform has no data directory")
+	}
+	return p.DataDir, nil
+}
+
+func (p *Platform) Register(options install.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.RegisterErr != nil {
+		return p.RegisterErr
+	}
+	options.Executable = nil
+	p.registered = &options
+	p.command = append([]string{options.ExecPath}, install.Arguments(options)...)
+	return nil
+}
+
+func (p *Platform) Unregister(options install.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.registered = nil
+	p.command = nil
+	return nil
+}
+
+func (p *Platform) Supported(options install.Options) install.Settings {
+	return install.Settings{
+		RunAtLoad: !p.Unsupported.RunAtLoad,
+		OnACPower: !p.Unsupported.OnACPower,
+		WhenIdle:  !p.Unsupported.WhenIdle,
+	}
+}
+
+func (p *Platform) Schedule(options install.Options) (*install.Schedule, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.registered == nil {
+		return nil, nil
+	}
+	settings := p.registered.Settings
+	return &install.Schedule{Enabled: true, Settings: &settings}, nil
+}
+
+func (p *Platform) RunNow(options install.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.registered == nil {
+		return errors.New("the signer is not registered")
+	}
+	p.runs++
+	return nil
+}
+
+func (p *Platform) CopyToClipboard(text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clipboard = text
+	return nil
+}
+
+func (p *Platform) Open(target string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.opened = append(p.opened, target)
+	return nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package install
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// launchdLabel is the label of the launch agent, as used by the previous
+// installers.
+const launchdLabel = "com.coiin.independent-signer"
+
+var plistTemplate = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{{.Label}}</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>{{.ExecPath}}</string>
+{{- range .Args}}
+        <string>{{.}}</string>
+{{- end}}
+    </array>
+    <key>StartInterval</key>
+    <integer>{{.Interval}}</integer>
+    <key>RunAtLoad</key>
+    {{if .RunAtLoad}}<true/>{{else}}<false/>{{end}}
+</dict>
+</plist>
+`))
+
+// Launchd runs the signer as a launch agent of the current user.
+type Launchd struct{}
+
+// DefaultPlatform returns the platform of the running operating system.
+func DefaultPlatform() Platform {
+	return Launchd{}
+}
+
+func (Launchd) Name(options Options) string {
+	return "launchd"
+}
+
+func (Launchd) DefaultDataDir(options Options) (string, error) {
+	if options.System {
+		return "", ErrSystemUnsupported
+	}
+	return signer.DefaultDataDir()
+}
+
+func plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func (Launchd) Register(options Options) error {
+	if options.System {
+		return ErrSystemUnsupported
+	}
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for _, arg := range Arguments(options) {
+		args = append(args, xmlEscape(arg))
+	}
+	var plist bytes.Buffer
+	err = plistTemplate.Execute(&plist, map[string]interface{}{
+		"Label":     launchdLabel,
+		"ExecPath":  xmlEscape(options.ExecPath),
+		"Args":      args,
+		"Interval":  int64(options.Settings.Interval.Seconds()),
+		"RunAtLoad": options.Settings.RunAtLoad,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, plist.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	// Replace a previously loaded agent, if any.
+	_ = exec.Command("launchctl", "remove", launchdLabel).Run()
+	return launchctl("load", "-F", path)
+}
+
+func (Launchd) Unregister(options Options) error {
+	if options.System {
+		return ErrSystemUnsupported
+	}
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if err := launchctl("remove", launchdLabel); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// lastExitStatus finds the exit status of the last run in the output of
+// launchctl list.
+var lastExitStatus = regexp.MustCompile(`"LastExitStatus" = (-?\d+);`)
+
+func (Launchd) Schedule(options Options) (*Schedule, error) {
+	output, err := exec.Command("launchctl", "list", launchdLabel).Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+
+	// A listed agent is loaded, and launchd runs loaded agents.
+	schedule := &Schedule{Enabled: true}
+	if match := lastExitStatus.FindSubmatch(output); match != nil {
+		schedule.LastResult = "exit status " + string(match[1])
+	}
+	schedule.Settings, _ = readPlistSettings()
+	return schedule, nil
+}
+
+func (Launchd) Supported(options Options) Settings {
+	// Agents cannot be held back on battery or until the computer is idle.
+	return Settings{RunAtLoad: true}
+}
+
+// readPlistSettings reads the settings back from the installed plist.
+func readPlistSettings() (*Settings, error) {
+	path, err := plistPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := plistValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	interval, err := strconv.ParseInt(values["StartInterval"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the StartInterval of %s: %w", path, err)
+	}
+	return &Settings{
+		Interval:  time.Duration(interval) * time.Second,
+		RunAtLoad: values["RunAtLoad"] == "true",
+	}, nil
+}
+
+// plistValues returns the scalar values of the top level dictionary of a
+// plist: integers and strings as text, booleans as "true" or "false".
+func plistValues(data []byte) (map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	values := make(map[string]string)
+	var key string
+	// The values of the top level dictionary are at depth 3, inside <plist>
+	// and <dict>.
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 3 {
+				continue
+			}
+			switch token.Name.Local {
+			case "key", "integer", "string":
+				var text string
+				if err := decoder.DecodeElement(&text, &token); err != nil {
+					return nil, err
+				}
+				depth--
+				if token.Name.Local == "key" {
+					key = text
+				} else {
+					values[key] = strings.TrimSpace(text)
+				}
+			case "true", "false":
+				values[key] = token.Name.Local
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+func (Launchd) RunNow(options Options) error {
+	return launchctl("start", launchdLabel)
+}
+
+func (Launchd) CopyToClipboard(text string) error {
+	return copyWith(exec.Command("pbcopy"), text)
+}
+
+func (Launchd) Open(target string) error {
+	return exec.Command("open", target).Run()
+}
+
+func launchctl(args ...string) error {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package install
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSystemUnsupported is returned when a system wide install is requested
+// from a platform that only supports installs for the current user.
+var ErrSystemUnsupported = errors.New("system wide installs are only supported on Linux")
+
+// Platform is how the signer is scheduled and integrated with the desktop on
+// an operating system. DefaultPlatform returns the one of the running
+// operating system; installers may pass another one in Options.
+type Platform interface {
+	// Name describes how the signer is run, e.g. "launchd".
+	Name(options Options) string
+	// DefaultDataDir is the data directory used when Options.DataDir is
+	// empty.
+	DefaultDataDir(options Options) (string, error)
+	// Register schedules options.ExecPath to run with Arguments(options) as
+	// described by options.Settings, replacing a previous registration.
+	Register(options Options) error
+	// Unregister removes the signer from the scheduler.
+	Unregister(options Options) error
+	// Supported returns the conditions of Settings the scheduler can apply:
+	// the boolean fields it supports are set.
+	Supported(options Options) Settings
+	// Schedule returns the state of the signer in the scheduler, or nil if
+	// it is not registered.
+	Schedule(options Options) (*Schedule, error)
+	// RunNow asks the scheduler to run the signer now, without waiting for
+	// the run to finish.
+	RunNow(options Options) error
+	// CopyToClipboard puts text on the clipboard.
+	CopyToClipboard(text string) error
+	// Open opens a file, folder or URL with the default application.
+	Open(target string) error
+}
+
+// Arguments returns the arguments the scheduler passes to options.ExecPath,
+// so that scheduled runs use the data directory the signer was installed
+// into rather than the default one.
+func Arguments(options Options) []string {
+	return []string{"-dataDir", options.DataDir}
+}
+
+// Schedule is the state of the signer in the scheduler, as far as the
+// scheduler reports it. Fields the scheduler does not report are empty.
+type Schedule struct {
+	// Enabled is false when the scheduler will not run the signer.
+	Enabled bool
+	LastRun string
+	NextRun string
+	// LastResult is the result of the last run, e.g. an exit code.
+	LastResult string
+	// Settings are the settings the signer is registered with, nil if they
+	// could not be read back from the scheduler.
+	Settings *Settings
+}
+
+// Settings are when the scheduler runs the signer. The zero value runs it
+// every DefaultInterval, without further conditions.
+type Settings struct {
+	// Interval is how often the signer runs. It defaults to DefaultInterval.
+	Interval time.Duration
+	// RunAtLoad also runs the signer when the scheduler loads it, at login or
+	// boot.
+	RunAtLoad bool
+	// OnACPower skips runs while the computer is on battery.
+	OnACPower bool
+	// WhenIdle only runs the signer while the computer is idle.
+	WhenIdle bool
+}
+
+// DefaultSettings are the settings installers offer by default.
+var DefaultSettings = Settings{Interval: DefaultInterval, RunAtLoad: true}
+
+// String describes the settings, e.g. "every 30m0s, at load".
+func (s Settings) String() string {
+	description := fmt.Sprintf("every %s", s.Interval)
+	if s.RunAtLoad {
+		description += ", at load"
+	}
+	if s.OnACPower {
+		description += ", only on AC power"
+	}
+	if s.WhenIdle {
+		description += ", only when idle"
+	}
+	return description
+}
+
+// ParseSettings applies a schedule given as comma separated terms to base. A
+// term is an interval such as "1h", or one of the conditions "runAtLoad",
+// "onACPower" and "whenIdle", optionally followed by "=true" or "=false".
+func ParseSettings(text string, base Settings) (Settings, error) {
+	settings := base
+	for _, term := range strings.Split(text, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if interval, err := time.ParseDuration(term); err == nil {
+			if interval <= 0 {
+				return base, fmt.Errorf("invalid interval %q", term)
+			}
+			settings.Interval = interval
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(term, "=")
+		enabled := true
+		if hasValue {
+			var err error
+			if enabled, err = strconv.ParseBool(value); err != nil {
+				return base, fmt.Errorf("invalid value of %s: %q", name, value)
+			}
+		}
+		switch name {
+		case "runAtLoad":
+			settings.RunAtLoad = enabled
+		case "onACPower":
+			settings.OnACPower = enabled
+		case "whenIdle":
+			settings.WhenIdle = enabled
+		default:
+			return base, fmt.Errorf("unknown schedule term %q", term)
+		}
+	}
+	return settings, nil
+}
+
+// checkSupported returns an error if options.Settings asks for conditions the
+// platform cannot apply.
+func checkSupported(options Options) error {
+	supported := options.Platform.Supported(options)
+	var unsupported []string
+	if options.Settings.RunAtLoad && !supported.RunAtLoad {
+		unsupported = append(unsupported, "running at load")
+	}
+	if options.Settings.OnACPower && !supported.OnACPower {
+		unsupported = append(unsupported, "running only on AC power")
+	}
+	if options.Settings.WhenIdle && !supported.WhenIdle {
+		unsupported = append(unsupported, "running only when idle")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("the %s does not support %s", options.Platform.Name(options), strings.Join(unsupported, " or "))
+	}
+	return nil
+}
+
+// copyWith writes text to the standard input of a clipboard command.
+func copyWith(cmd *exec.Cmd, text string) error {
+	cmd.Stdin = strings.NewReader(text)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// xmlEscape escapes text for XML character data.
+func xmlEscape(text string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+//go:build !linux && !darwin && !windows
+
+package install
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// unsupported is the platform of operating systems the signer cannot
+// schedule itself on. The signer still runs from cron there.
+type unsupported struct{}
+
+// DefaultPlatform returns the platform of the running operating system.
+func DefaultPlatform() Platform {
+	return unsupported{}
+}
+
+func (unsupported) Name(options Options) string {
+	return "none"
+}
+
+func (unsupported) DefaultDataDir(options Options) (string, error) {
+	if options.System {
+		return "", ErrSystemUnsupported
+	}
+	return signer.DefaultDataDir()
+}
+
+func (unsupported) Register(options Options) error {
+	return fmt.Errorf("scheduling the signer is not supported on %s, run it from cron instead", runtime.GOOS)
+}
+
+func (p unsupported) Unregister(options Options) error {
+	return p.Register(options)
+}
+
+func (unsupported) Supported(options Options) Settings {
+	return Settings{}
+}
+
+func (unsupported) Schedule(options Options) (*Schedule, error) {
+	return nil, nil
+}
+
+func (p unsupported) RunNow(options Options) error {
+	return p.Register(options)
+}
+
+func (unsupported) CopyToClipboard(text string) error {
+	return fmt.Errorf("the clipboard is not supported on %s", runtime.GOOS)
+}
+
+func (unsupported) Open(target string) error {
+	return fmt.Errorf("opening %s is not supported on %s", target, runtime.GOOS)
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package install
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/systemd"
+)
+
+// Systemd runs the signer from a systemd timer, either for the current user
+// or, with Options.System, for the whole machine.
+type Systemd struct{}
+
+// DefaultPlatform returns the platform of the running operating system.
+func DefaultPlatform() Platform {
+	return Systemd{}
+}
+
+func (Systemd) Name(options Options) string {
+	if options.System {
+		return "systemd system timer"
+	}
+	return "systemd user timer"
+}
+
+func (Systemd) DefaultDataDir(options Options) (string, error) {
+	if options.System {
+		return systemd.SystemDataDir, nil
+	}
+	return signer.DefaultDataDir()
+}
+
+func (Systemd) Register(options Options) error {
+	units := systemd.Options{
+		System:    options.System,
+		ExecPath:  options.ExecPath,
+		Interval:  options.Settings.Interval,
+		RunAtBoot: options.Settings.RunAtLoad,
+		OnACPower: options.Settings.OnACPower,
+	}
+	if !options.System {
+		// The system service is always given its StateDirectory.
+		units.Args = Arguments(options)
+	}
+	return systemd.Install(units)
+}
+
+func (Systemd) Unregister(options Options) error {
+	return systemd.Uninstall(options.System)
+}
+
+func (Systemd) Schedule(options Options) (*Schedule, error) {
+	status, err := systemd.Status(options.System)
+	if status == nil || err != nil {
+		return nil, err
+	}
+	schedule := &Schedule{
+		Enabled:    status.Enabled == "enabled",
+		LastRun:    status.LastRun,
+		NextRun:    status.NextRun,
+		LastResult: status.Result,
+	}
+	if dir, err := systemd.UnitDir(options.System); err == nil {
+		if units, err := systemd.ReadUnits(dir, options.System); err == nil {
+			schedule.Settings = &Settings{Interval: units.Interval, RunAtLoad: units.RunAtBoot, OnACPower: units.OnACPower}
+		}
+	}
+	return schedule, nil
+}
+
+func (Systemd) Supported(options Options) Settings {
+	// Timers cannot wait until the computer is idle.
+	return Settings{RunAtLoad: true, OnACPower: true}
+}
+
+func (Systemd) RunNow(options Options) error {
+	return systemd.Start(options.System)
+}
+
+func (Systemd) CopyToClipboard(text string) error {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if path, err := exec.LookPath("wl-copy"); err == nil {
+			return copyWith(exec.Command(path), text)
+		}
+	}
+	if path, err := exec.LookPath("xclip"); err == nil {
+		return copyWith(exec.Command(path, "-selection", "clipboard"), text)
+	}
+	if path, err := exec.LookPath("xsel"); err == nil {
+		return copyWith(exec.Command(path, "--clipboard", "--input"), text)
+	}
+	return errors.New("no clipboard command found, install wl-clipboard, xclip or xsel")
+}
+
+func (Systemd) Open(target string) error {
+	return exec.Command("xdg-open", target).Start()
+}
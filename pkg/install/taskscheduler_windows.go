@@ -0,0 +1,344 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package install
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+const (
+	// taskName is the name of the scheduled task, as used by the previous
+	// installers.
+	taskName = "IndependentSigner"
+
+	// createNoWindow keeps schtasks from flashing a console window when it is
+	// run from a GUI installer.
+	createNoWindow = 0x08000000
+
+	// maxRepetitionMinutes is the longest interval a task can repeat at, 31
+	// days.
+	maxRepetitionMinutes = 31 * 24 * 60
+)
+
+// taskTemplate is the definition of the scheduled task. Like the task the
+// previous installers created with schtasks /np, it runs as the current user
+// without storing a password, with access to local resources only.
+var taskTemplate = template.Must(template.New("task").Parse(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Runs the NVL independent signer</Description>
+  </RegistrationInfo>
+  <Triggers>
+    <TimeTrigger>
+      <Repetition>
+        <Interval>{{.Interval}}</Interval>
+        <StopAtDurationEnd>false</StopAtDurationEnd>
+      </Repetition>
+      <StartBoundary>{{.Start}}</StartBoundary>
+      <Enabled>true</Enabled>
+    </TimeTrigger>
+{{- if .RunAtLoad}}
+    <LogonTrigger>
+      <UserId>{{.UserID}}</UserId>
+      <Enabled>true</Enabled>
+    </LogonTrigger>
+{{- end}}
+  </Triggers>
+  <Principals>
+    <Principal id="Author">
+      <UserId>{{.UserID}}</UserId>
+      <LogonType>S4U</LogonType>
+      <RunLevel>LeastPrivilege</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>{{.OnACPower}}</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>{{.OnACPower}}</StopIfGoingOnBatteries>
+    <RunOnlyIfIdle>{{.WhenIdle}}</RunOnlyIfIdle>
+    <IdleSettings>
+      <StopOnIdleEnd>false</StopOnIdleEnd>
+      <RestartOnIdle>false</RestartOnIdle>
+    </IdleSettings>
+    <Enabled>true</Enabled>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>{{.ExecPath}}</Command>
+      <Arguments>{{.Arguments}}</Arguments>
+    </Exec>
+  </Actions>
+</Task>
+`))
+
+// TaskScheduler runs the signer as a scheduled task of the current user.
+type TaskScheduler struct{}
+
+// DefaultPlatform returns the platform of the running operating system.
+func DefaultPlatform() Platform {
+	return TaskScheduler{}
+}
+
+func (TaskScheduler) Name(options Options) string {
+	return "Task Scheduler"
+}
+
+func (TaskScheduler) DefaultDataDir(options Options) (string, error) {
+	if options.System {
+		return "", ErrSystemUnsupported
+	}
+	return signer.DefaultDataDir()
+}
+
+func (TaskScheduler) Register(options Options) error {
+	if options.System {
+		return ErrSystemUnsupported
+	}
+	minutes := int(options.Settings.Interval.Minutes())
+	if minutes < 1 || minutes > maxRepetitionMinutes || options.Settings.Interval%time.Minute != 0 {
+		return fmt.Errorf("the Task Scheduler cannot run the signer every %s", options.Settings.Interval)
+	}
+	current, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for _, arg := range Arguments(options) {
+		args = append(args, syscall.EscapeArg(arg))
+	}
+	var task strings.Builder
+	err = taskTemplate.Execute(&task, map[string]interface{}{
+		"UserID":    xmlEscape(current.Username),
+		"ExecPath":  xmlEscape(options.ExecPath),
+		"Arguments": xmlEscape(strings.Join(args, " ")),
+		"Start":     time.Now().Format("2006-01-02T15:04:05"),
+		"Interval":  fmt.Sprintf("PT%dM", minutes),
+		"RunAtLoad": options.Settings.RunAtLoad,
+		"OnACPower": options.Settings.OnACPower,
+		"WhenIdle":  options.Settings.WhenIdle,
+	})
+	if err != nil {
+		return err
+	}
+
+	// schtasks only reads task definitions from files, in UTF-16.
+	file, err := os.CreateTemp("", "independent-signer-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(encodeUTF16(task.String()))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return schtasks("/create", "/tn", taskName, "/xml", file.Name(), "/f")
+}
+
+func (TaskScheduler) Unregister(options Options) error {
+	if options.System {
+		return ErrSystemUnsupported
+	}
+	return schtasks("/delete", "/tn", taskName, "/f")
+}
+
+func (TaskScheduler) Schedule(options Options) (*Schedule, error) {
+	output, err := command("/query", "/tn", taskName, "/fo", "LIST", "/v").Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The field names are only known for English installs of Windows;
+	// elsewhere the task is reported as enabled with unknown run times.
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	schedule := &Schedule{
+		Enabled:    fields["Scheduled Task State"] != "Disabled" && fields["Status"] != "Disabled",
+		LastRun:    fields["Last Run Time"],
+		NextRun:    fields["Next Run Time"],
+		LastResult: fields["Last Result"],
+	}
+	schedule.Settings, _ = readTaskSettings()
+	return schedule, nil
+}
+
+func (TaskScheduler) Supported(options Options) Settings {
+	return Settings{RunAtLoad: true, OnACPower: true, WhenIdle: true}
+}
+
+// taskDefinition is the part of an exported task definition the settings are
+// read back from. Elements left out of a definition have their default value,
+// so the conditions are pointers.
+type taskDefinition struct {
+	Triggers struct {
+		TimeTrigger []struct {
+			Repetition struct {
+				Interval string
+			}
+		}
+		LogonTrigger []struct{}
+	}
+	Settings struct {
+		DisallowStartIfOnBatteries *bool
+		RunOnlyIfIdle              *bool
+	}
+}
+
+// readTaskSettings reads the settings back from the definition of the
+// scheduled task. It also reads the tasks of the previous installers, which
+// ran the signer from a time trigger repeated every 30 minutes.
+func readTaskSettings() (*Settings, error) {
+	output, err := command("/query", "/tn", taskName, "/xml").Output()
+	if err != nil {
+		return nil, fmt.Errorf("schtasks /query /xml: %w", err)
+	}
+	if bytes.HasPrefix(output, []byte{0xff, 0xfe}) {
+		output = []byte(decodeUTF16(output[2:]))
+	}
+
+	var task taskDefinition
+	decoder := xml.NewDecoder(bytes.NewReader(output))
+	// The definition declares the encoding it had before it was written to
+	// the console, the text is already decoded.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&task); err != nil {
+		return nil, fmt.Errorf("failed to parse the scheduled task: %w", err)
+	}
+	if len(task.Triggers.TimeTrigger) == 0 {
+		return nil, errors.New("the scheduled task has no time trigger")
+	}
+	interval, err := parseTaskDuration(task.Triggers.TimeTrigger[0].Repetition.Interval)
+	if err != nil {
+		return nil, err
+	}
+	settings := &Settings{
+		Interval:  interval,
+		RunAtLoad: len(task.Triggers.LogonTrigger) > 0,
+		// The Task Scheduler holds tasks back on battery by default.
+		OnACPower: true,
+	}
+	if disallow := task.Settings.DisallowStartIfOnBatteries; disallow != nil {
+		settings.OnACPower = *disallow
+	}
+	if idle := task.Settings.RunOnlyIfIdle; idle != nil {
+		settings.WhenIdle = *idle
+	}
+	return settings, nil
+}
+
+var taskDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseTaskDuration parses the ISO 8601 durations of task definitions, e.g.
+// "PT30M".
+func parseTaskDuration(text string) (time.Duration, error) {
+	match := taskDuration.FindStringSubmatch(text)
+	if match == nil || text == "P" || text == "PT" {
+		return 0, fmt.Errorf("invalid task duration %q", text)
+	}
+	var duration time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(match[i+1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid task duration %q: %w", text, err)
+		}
+		duration += time.Duration(n) * unit
+	}
+	return duration, nil
+}
+
+// encodeUTF16 encodes text as little endian UTF-16 with a byte order mark.
+func encodeUTF16(text string) []byte {
+	encoded := []byte{0xff, 0xfe}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+	}
+	return encoded
+}
+
+// decodeUTF16 decodes little endian UTF-16 without a byte order mark.
+func decodeUTF16(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func (TaskScheduler) RunNow(options Options) error {
+	return schtasks("/run", "/tn", taskName)
+}
+
+func (TaskScheduler) CopyToClipboard(text string) error {
+	cmd := exec.Command("clip")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+	return copyWith(cmd, text)
+}
+
+func (TaskScheduler) Open(target string) error {
+	// explorer exits with status 1 even when it opened the target.
+	err := exec.Command("explorer", target).Run()
+	if _, ok := err.(*exec.ExitError); ok {
+		return nil
+	}
+	return err
+}
+
+func command(args ...string) *exec.Cmd {
+	cmd := exec.Command("schtasks", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+	return cmd
+}
+
+func schtasks(args ...string) error {
+	output, err := command(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("schtasks %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package logfile writes the signer log to size and age rotated files, so
+// runs started by launchd or the Task Scheduler, whose output is discarded,
+// leave a trace.
+package logfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Dirname is the folder of the data directory the log files are kept in.
+	Dirname = "logs"
+	// Filename is the file the current log is written to. Rotated files are
+	// named after it with the time of the rotation, e.g.
+	// independent-signer-20231019T101500.123456789.log.
+	Filename = "independent-signer.log"
+
+	prefix          = "independent-signer-"
+	suffix          = ".log"
+	rotatedLayout   = "20060102T150405.000000000"
+	timestampLayout = "2006/01/02 15:04:05"
+)
+
+// Options configure rotation and retention.
+type Options struct {
+	// MaxSize is the size in bytes above which the log is rotated.
+	MaxSize int64
+	// MaxAge is the age of the first entry above which the log is rotated.
+	MaxAge time.Duration
+	// MaxFiles is how many rotated files are kept, 0 keeps them all.
+	MaxFiles int
+}
+
+// DefaultOptions rotate daily or at 5 MB and keep two weeks of daily logs.
+func DefaultOptions() Options {
+	return Options{
+		MaxSize:  5 << 20,
+		MaxAge:   24 * time.Hour,
+		MaxFiles: 14,
+	}
+}
+
+// Open rotates the log in dir if it is due and opens it for appending.
+func Open(dir string, options Options) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, Filename)
+	if rotate, err := due(path, options, time.Now()); err != nil {
+		return nil, err
+	} else if rotate {
+		// Another run may be rotating at the same time, in which case the
+		// file is already gone.
+		rotated, err := rotatedPath(dir, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Rename(path, rotated); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err := prune(dir, options.MaxFiles); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+// rotatedPath returns the path the log in dir is rotated to at now. A path
+// that is already taken is skipped, so that two rotations within the
+// resolution of the clock do not overwrite one another.
+func rotatedPath(dir string, now time.Time) (string, error) {
+	for {
+		path := filepath.Join(dir, prefix+now.UTC().Format(rotatedLayout)+suffix)
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+		now = now.Add(time.Nanosecond)
+	}
+}
+
+// due reports whether the log at path should be rotated.
+func due(path string, options Options, now time.Time) (bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if info.Size() == 0 {
+		return false, nil
+	}
+	if options.MaxSize > 0 && info.Size() >= options.MaxSize {
+		return true, nil
+	}
+	if options.MaxAge > 0 {
+		started, err := firstEntry(path)
+		if err != nil {
+			started = info.ModTime()
+		}
+		return now.Sub(started) >= options.MaxAge, nil
+	}
+	return false, nil
+}
+
+// firstEntry returns the time of the first entry of the log at path, as
+// written by the standard log package.
+func firstEntry(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	line := make([]byte, len(timestampLayout))
+	if _, err := io.ReadFull(file, line); err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(timestampLayout, string(line), time.Local)
+}
+
+// Rotated returns the rotated log files in dir, oldest first.
+func Rotated(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	// The timestamps in the names sort chronologically.
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func prune(dir string, maxFiles int) error {
+	if maxFiles <= 0 {
+		return nil
+	}
+	paths, err := Rotated(dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > maxFiles {
+		if err := os.Remove(paths[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// Tail returns the last n lines logged in dir, reading rotated files as well
+// when the current one is shorter.
+func Tail(dir string, n int) ([]string, error) {
+	paths, err := Rotated(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, filepath.Join(dir, Filename))
+
+	var lines []string
+	for i := len(paths) - 1; i >= 0 && len(lines) < n; i-- {
+		data, err := os.ReadFile(paths[i])
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		fileLines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(fileLines) == 1 && fileLines[0] == "" {
+			continue
+		}
+		lines = append(fileLines, lines...)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// Follow writes what is appended to the current log in dir to w until stop
+// is closed, starting at its current end. It follows the log across
+// rotations by file identity rather than size, so a rotation is noticed even
+// when the new log has already grown past the old offset: the rest of the
+// rotated file is written first, then the new log from its start.
+func Follow(dir string, w io.Writer, stop <-chan struct{}) error {
+	path := filepath.Join(dir, Filename)
+	var followed os.FileInfo
+	var offset int64
+	if file, info, err := openLog(path); err == nil {
+		file.Close()
+		followed, offset = info, info.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		// The log is reopened on every tick rather than held open, so that
+		// rotating it by renaming is never blocked on Windows.
+		file, info, err := openLog(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case followed != nil && !os.SameFile(followed, info):
+			// The log was rotated.
+			if err := finishRotated(dir, followed, offset, w); err != nil {
+				file.Close()
+				return err
+			}
+			offset = 0
+		case info.Size() < offset:
+			// The log was truncated.
+			offset = 0
+		}
+		followed = info
+		read, err := copyFrom(file, offset, w)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		offset += read
+	}
+}
+
+// openLog opens path and stats the open file, so that the identity compared
+// by os.SameFile is that of the file read and not whatever path names later.
+func openLog(path string) (*os.File, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// finishRotated writes the rest of followed, from offset, to w after it was
+// rotated. Nothing is written if it was already pruned.
+func finishRotated(dir string, followed os.FileInfo, offset int64, w io.Writer) error {
+	rotated, err := Rotated(dir)
+	if err != nil {
+		return err
+	}
+	for i := len(rotated) - 1; i >= 0; i-- {
+		file, info, err := openLog(rotated[i])
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !os.SameFile(followed, info) {
+			file.Close()
+			continue
+		}
+		_, err = copyFrom(file, offset, w)
+		file.Close()
+		return err
+	}
+	return nil
+}
+
+func copyFrom(file *os.File, offset int64, w io.Writer) (int64, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, bufio.NewReader(file))
+}
+
+// ParseMode parses the -logFiles setting: "auto" writes log files only when
+// stderr is neither a terminal nor the systemd journal, which already keeps
+// the output of scheduled runs; "on" always and "off" never.
+func ParseMode(mode string, stderr *os.File) (bool, error) {
+	switch mode {
+	case "auto":
+		return !isTerminal(stderr) && !isJournal(stderr), nil
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid log file mode %q, expected auto, on or off", mode)
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package logfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer lets the test read what Follow writes from another goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if out.String() == want {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("followed %q, want %q", out.String(), want)
+}
+
+func write(t *testing.T, file *os.File, line string) {
+	t.Helper()
+	if _, err := file.WriteString(line); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	options := Options{MaxSize: 8}
+	old, err := Open(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	write(t, old, "before\n")
+
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- Follow(dir, out, stop) }()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+	// Let Follow find the end of the log before anything else is written.
+	time.Sleep(200 * time.Millisecond)
+
+	write(t, old, "second\n")
+	waitFor(t, out, "second\n")
+
+	// The new log grows past the old offset before Follow looks again, so
+	// only its identity tells that it was rotated.
+	write(t, old, "third\n")
+	current, err := Open(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer current.Close()
+	write(t, current, "a fourth line, longer than the whole old log\n")
+	if rotated, err := Rotated(dir); err != nil || len(rotated) != 1 {
+		t.Fatalf("rotated %v, %v, want one file", rotated, err)
+	}
+	waitFor(t, out, "second\nthird\na fourth line, longer than the whole old log\n")
+}
+
+func TestFollowTruncated(t *testing.T) {
+	dir := t.TempDir()
+	file, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	write(t, file, "before the truncation\n")
+
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- Follow(dir, out, stop) }()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := os.Truncate(filepath.Join(dir, Filename), 0); err != nil {
+		t.Fatal(err)
+	}
+	write(t, file, "after\n")
+	waitFor(t, out, "after\n")
+}
+
+func TestParseMode(t *testing.T) {
+	t.Setenv("JOURNAL_STREAM", "")
+	file, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for mode, want := range map[string]bool{"auto": true, "on": true, "off": false} {
+		if got, err := ParseMode(mode, file); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %t, %v, want %t", mode, got, err, want)
+		}
+	}
+	if _, err := ParseMode("sometimes", file); err == nil || !strings.Contains(err.Error(), "sometimes") {
+		t.Errorf("ParseMode(sometimes) = %v, want an error", err)
+	}
+}
+
+func TestRotateWithinOneSecond(t *testing.T) {
+	dir := t.TempDir()
+	options := Options{MaxSize: 1}
+	for _, line := range []string{"first\n", "second\n", "third\n", "current\n"} {
+		file, err := Open(dir, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, file, line)
+		file.Close()
+	}
+	lines, err := Tail(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(lines, ","); got != "first,second,third,current" {
+		t.Errorf("logs hold %s, want every line in order", got)
+	}
+
+	// A rotation at the same instant as an earlier one gets another name.
+	now := time.Now()
+	taken, err := rotatedPath(dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(taken, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := rotatedPath(dir, now); err != nil || path == taken {
+		t.Errorf("rotated to %s, %v, want a path other than %s", path, err, taken)
+	}
+}
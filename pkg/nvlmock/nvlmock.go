@@ -0,0 +1,539 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package nvlmock is an in-memory stand-in for the NVL Proxy API, used to run
+// the independent signer end to end without talking to nvl.api.coiin.ai.
+//
+// A Server implements http.Handler, so it can be served with
+// httptest.NewServer in tests or with http.ListenAndServe by cmd/nvl-mock.
+package nvlmock
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// Faults are misbehaviours the server can be told to inject.
+type Faults struct {
+	// BadSignature serves proxy blocks with a corrupted signature.
+	BadSignature bool
+	// ServerError answers every API request with a 500.
+	ServerError bool
+	// Delay is added before every API response.
+	Delay time.Duration
+	// BadChildSignature serves child blocks with a corrupted signature.
+	BadChildSignature bool
+	// ClockOffset shifts the Date header and new block timestamps, as if the
+	// proxy clock were that far ahead of the local clock.
+	ClockOffset time.Duration
+}
+
+// Server is a mock NVL Proxy.
+type Server struct {
+	mu sync.Mutex
+
+	key      *ecdsa.PrivateKey
+	childKey *ecdsa.PrivateKey
+	faults   Faults
+
+	blocks      map[string]*signer.NVLBlock
+	proxyChain  []string
+	independent map[string][]string
+	enqueued    []*signer.NVLBlock
+
+	// challenges maps unused registration challenges to the public key they
+	// were issued for.
+	challenges          map[string]*challenge
+	registrations       map[string]*signer.Registration
+	requireRegistration bool
+}
+
+type challenge struct {
+	publicKey string
+	expiresAt time.Time
+}
+
+// New returns a server with a freshly generated proxy key and no blocks.
+func New() (*Server, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	childKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		key:           key,
+		childKey:      childKey,
+		blocks:        make(map[string]*signer.NVLBlock),
+		independent:   make(map[string][]string),
+		challenges:    make(map[string]*challenge),
+		registrations: make(map[string]*signer.Registration),
+	}, nil
+}
+
+// PublicKey returns the hex encoded public key the proxy currently reports.
+func (s *Server) PublicKey() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return publicKeyHex(s.key)
+}
+
+// ChildPublicKey returns the hex encoded public key child blocks are signed
+// with.
+func (s *Server) ChildPublicKey() string {
+	return publicKeyHex(s.childKey)
+}
+
+// SetFaults replaces the injected faults.
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+}
+
+func (s *Server) Faults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults
+}
+
+// RotateKey switches the proxy to a new signing key. Blocks already produced
+// keep their old signatures, so they no longer verify against the reported key.
+func (s *Server) RotateKey() error {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = key
+	return nil
+}
+
+// AddBlock produces a new proxy block sealing children and returns its hash.
+func (s *Server) AddBlock(coiinSupply string, children ...string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if children == nil {
+		children = make([]string, 0)
+	}
+	prior := ""
+	if len(s.proxyChain) > 0 {
+		prior = s.proxyChain[len(s.proxyChain)-1]
+	}
+	block := &signer.NVLBlock{
+		Version: "1",
+		Header: &signer.NVLBlockHeader{
+			Type:        "PROXY",
+			PriorBlock:  prior,
+			Timestamp:   strconv.FormatInt(time.Now().Add(s.faults.ClockOffset).Unix(), 10),
+			PublicKey:   publicKeyHex(s.key),
+			CoiinSupply: coiinSupply,
+		},
+		Blocks: children,
+		Seal:   &signer.NVLBlockSeal{},
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return "", err
+	}
+	signature, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return "", err
+	}
+	block.Seal.Proofs = hex.EncodeToString(hash)
+	block.Seal.Signature = hex.EncodeToString(signature)
+
+	s.blocks[block.Seal.Proofs] = block
+	s.proxyChain = append(s.proxyChain, block.Seal.Proofs)
+	return block.Seal.Proofs, nil
+}
+
+// AddChildBlock produces a block of blockType, signed by a key of its own, for
+// a later proxy block to seal. It returns the block hash.
+func (s *Server) AddChildBlock(blockType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block := &signer.NVLBlock{
+		Version: "1",
+		Header: &signer.NVLBlockHeader{
+			Type:      blockType,
+			Timestamp: strconv.FormatInt(time.Now().UnixNano(), 10),
+			PublicKey: publicKeyHex(s.childKey),
+		},
+		Blocks: make([]string, 0),
+		Seal:   &signer.NVLBlockSeal{},
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return "", err
+	}
+	signature, err := crypto.Sign(hash, s.childKey)
+	if err != nil {
+		return "", err
+	}
+	block.Seal.Proofs = hex.EncodeToString(hash)
+	block.Seal.Signature = hex.EncodeToString(signature)
+
+	s.blocks[block.Seal.Proofs] = block
+	return block.Seal.Proofs, nil
+}
+
+// Enqueued returns every independent block accepted so far.
+func (s *Server) Enqueued() []*signer.NVLBlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*signer.NVLBlock(nil), s.enqueued...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	faults := s.Faults()
+	if faults.Delay > 0 {
+		select {
+		case <-time.After(faults.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Date", time.Now().Add(faults.ClockOffset).UTC().Format(http.TimeFormat))
+	if faults.ServerError {
+		http.Error(w, "injected server error", http.StatusInternalServerError)
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case path == "/api/v1/status" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"publicKey": s.PublicKey()})
+	case path == "/api/v1/blocks" && r.Method == http.MethodGet:
+		s.serveBlockList(w, r, s.proxyHashes())
+	case path == "/api/v1/independent/blocks" && r.Method == http.MethodGet:
+		s.serveBlockList(w, r, s.independentHashes(r.URL.Query().Get("publicKey")))
+	case strings.HasPrefix(path, "/api/v1/blocks/") && r.Method == http.MethodGet:
+		s.serveBlock(w, strings.TrimPrefix(path, "/api/v1/blocks/"), faults)
+	case path == "/api/v1/independent/enqueue" && r.Method == http.MethodPost:
+		s.serveEnqueue(w, r)
+	case path == "/api/v1/registration/challenge" && r.Method == http.MethodPost:
+		s.serveChallenge(w, r)
+	case path == "/api/v1/registration" && r.Method == http.MethodPost:
+		s.serveRegister(w, r)
+	case strings.HasPrefix(path, "/api/v1/registration/") && r.Method == http.MethodGet:
+		s.serveRegistration(w, strings.TrimPrefix(path, "/api/v1/registration/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) proxyHashes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.proxyChain...)
+}
+
+func (s *Server) independentHashes(publicKey string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.independent[publicKey]...)
+}
+
+// serveBlockList lists hashes newest first, limited by the size parameter.
+func (s *Server) serveBlockList(w http.ResponseWriter, r *http.Request, hashes []string) {
+	size := len(hashes)
+	if param := r.URL.Query().Get("size"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid size", http.StatusBadRequest)
+			return
+		}
+		if n < size {
+			size = n
+		}
+	}
+
+	type entry struct {
+		Hash string `json:"hash"`
+	}
+	list := struct {
+		Blocks []entry `json:"blocks"`
+	}{Blocks: make([]entry, 0, size)}
+	for i := len(hashes) - 1; i >= len(hashes)-size; i-- {
+		list.Blocks = append(list.Blocks, entry{Hash: hashes[i]})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) serveBlock(w http.ResponseWriter, hash string, faults Faults) {
+	s.mu.Lock()
+	block, ok := s.blocks[hash]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "block not found", http.StatusNotFound)
+		return
+	}
+
+	isChild := block.Header.Type != "PROXY" && block.Header.Type != signer.BlockTypeIndependent
+	if (faults.BadSignature && block.Header.Type == "PROXY") || (faults.BadChildSignature && isChild) {
+		corrupted := *block
+		seal := *block.Seal
+		seal.Signature = corruptSignature(seal.Signature)
+		corrupted.Seal = &seal
+		block = &corrupted
+	}
+	writeJSON(w, http.StatusOK, block)
+}
+
+func (s *Server) serveEnqueue(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := &struct {
+		Version                  string           `json:"version"`
+		Block                    *signer.NVLBlock `json:"block"`
+		IndependentSignerVersion string           `json:"independentSignerVersion"`
+	}{}
+	if err := json.Unmarshal(body, request); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Version != "1" {
+		http.Error(w, fmt.Sprintf("unsupported request version %q", request.Version), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requireRegistration && request.Block != nil && request.Block.Header != nil {
+		if registration := s.registrations[request.Block.Header.PublicKey]; registration == nil || registration.Status != signer.RegistrationRegistered {
+			http.Error(w, "public key is not registered", http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.validateIndependentBlock(request.Block); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	block := request.Block
+	s.blocks[block.Seal.Proofs] = block
+	s.independent[block.Header.PublicKey] = append(s.independent[block.Header.PublicKey], block.Seal.Proofs)
+	s.enqueued = append(s.enqueued, block)
+	writeJSON(w, http.StatusCreated, map[string]string{"hash": block.Seal.Proofs})
+}
+
+// SetRequireRegistration makes the server refuse blocks from public keys that
+// are not registered, like the NVL Proxy does.
+func (s *Server) SetRequireRegistration(require bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requireRegistration = require
+}
+
+// ClaimRegistration completes the pending registration of publicKey, as if it
+// had been claimed in the Coiin Console.
+func (s *Server) ClaimRegistration(publicKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registration, ok := s.registrations[publicKey]
+	if !ok {
+		return fmt.Errorf("public key %s has no pending registration", publicKey)
+	}
+	now := time.Now().UTC()
+	registration.Status = signer.RegistrationRegistered
+	registration.ClaimURL = ""
+	registration.RegisteredAt = &now
+	return nil
+}
+
+func (s *Server) serveChallenge(w http.ResponseWriter, r *http.Request) {
+	request := &struct {
+		PublicKey string `json:"publicKey"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil || request.PublicKey == "" {
+		http.Error(w, "a publicKey is required", http.StatusBadRequest)
+		return
+	}
+
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	issued := &signer.RegistrationChallenge{
+		Challenge: hex.EncodeToString(value),
+		ExpiresAt: time.Now().Add(10 * time.Minute).UTC(),
+	}
+	s.mu.Lock()
+	s.challenges[issued.Challenge] = &challenge{publicKey: request.PublicKey, expiresAt: issued.ExpiresAt}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, issued)
+}
+
+// serveRegister accepts a signed challenge, which leaves the registration
+// pending until it is claimed, or a signed console token. Any token is
+// accepted, the mock has no accounts to check it against.
+func (s *Server) serveRegister(w http.ResponseWriter, r *http.Request) {
+	request := &signer.RegistrationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Version != "1" {
+		http.Error(w, fmt.Sprintf("unsupported request version %q", request.Version), http.StatusBadRequest)
+		return
+	}
+	if (request.Challenge == "") == (request.Token == "") {
+		http.Error(w, "exactly one of challenge and token is required", http.StatusBadRequest)
+		return
+	}
+	signed := request.Token
+	if request.Challenge != "" {
+		signed = request.Challenge
+	}
+	if valid, err := signer.VerifyRegistration(request.PublicKey, signed, request.Signature); err != nil || !valid {
+		http.Error(w, "signature does not match the public key", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registration := &signer.Registration{PublicKey: request.PublicKey, Status: signer.RegistrationRegistered}
+	if request.Challenge != "" {
+		issued, ok := s.challenges[request.Challenge]
+		if !ok || issued.publicKey != request.PublicKey || time.Now().After(issued.expiresAt) {
+			http.Error(w, "unknown or expired challenge", http.StatusUnauthorized)
+			return
+		}
+		delete(s.challenges, request.Challenge)
+		if current := s.registrations[request.PublicKey]; current != nil && current.Status == signer.RegistrationRegistered {
+			writeJSON(w, http.StatusOK, current)
+			return
+		}
+		registration.Status = signer.RegistrationPending
+		registration.ClaimURL = "http://" + r.Host + "/mock/claim?publicKey=" + request.PublicKey
+	} else {
+		now := time.Now().UTC()
+		registration.RegisteredAt = &now
+	}
+	s.registrations[request.PublicKey] = registration
+	writeJSON(w, http.StatusCreated, registration)
+}
+
+func (s *Server) serveRegistration(w http.ResponseWriter, publicKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registration, ok := s.registrations[publicKey]
+	if !ok {
+		http.Error(w, "public key is not registered", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, registration)
+}
+
+// validateIndependentBlock checks an enqueued block the way the proxy would.
+// It must be called with s.mu held.
+func (s *Server) validateIndependentBlock(block *signer.NVLBlock) error {
+	if block == nil || block.Header == nil || block.Seal == nil {
+		return errors.New("block is incomplete")
+	}
+	if block.Version != "1" {
+		return fmt.Errorf("unsupported block version %q", block.Version)
+	}
+	if block.Header.Type != signer.BlockTypeIndependent {
+		return fmt.Errorf("block type is %q, not %s", block.Header.Type, signer.BlockTypeIndependent)
+	}
+	if len(block.Blocks) != 1 {
+		return fmt.Errorf("block attests %d proxy blocks, expected 1", len(block.Blocks))
+	}
+	if proxy, ok := s.blocks[block.Blocks[0]]; !ok || proxy.Header.Type != "PROXY" {
+		return fmt.Errorf("unknown proxy block %s", block.Blocks[0])
+	}
+	if _, err := strconv.ParseInt(block.Header.Timestamp, 10, 64); err != nil {
+		return fmt.Errorf("invalid timestamp %q", block.Header.Timestamp)
+	}
+
+	chain := s.independent[block.Header.PublicKey]
+	if block.Header.PriorBlock != "" && (len(chain) == 0 || chain[len(chain)-1] != block.Header.PriorBlock) {
+		return fmt.Errorf("prior block %s is not the latest block for this key", block.Header.PriorBlock)
+	}
+	if _, ok := s.blocks[block.Seal.Proofs]; ok {
+		return fmt.Errorf("block %s was already enqueued", block.Seal.Proofs)
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash) != block.Seal.Proofs {
+		return fmt.Errorf("block hash %s does not match its contents", block.Seal.Proofs)
+	}
+
+	publicKey, err := hexutil.Decode("0x" + block.Header.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if valid, err := signer.VerifyBlock(publicKey, block); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	} else if !valid {
+		return errors.New("signature does not match the block public key")
+	}
+	return nil
+}
+
+func corruptSignature(signature string) string {
+	if signature == "" {
+		return signature
+	}
+	flipped := "0"
+	if signature[0] == '0' {
+		flipped = "1"
+	}
+	return flipped + signature[1:]
+}
+
+func publicKeyHex(key *ecdsa.PrivateKey) string {
+	return hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// WriteFileAtomic writes data to path so that a crash or power loss leaves
+// either the old or the new contents, never a truncated file. The data is
+// written to a temporary file in the same directory, flushed to disk and then
+// renamed over path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomicFunc(path, data, perm, os.Rename)
+}
+
+// CreateFileAtomic is like WriteFileAtomic but refuses to replace an existing
+// file.
+func CreateFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomicFunc(path, data, perm, func(tmpPath, path string) error {
+		// A hard link fails if path already exists, which a rename would not.
+		if err := os.Link(tmpPath, path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("refusing to overwrite existing file %s", path)
+			}
+			return err
+		}
+		return os.Remove(tmpPath)
+	})
+}
+
+func writeFileAtomicFunc(path string, data []byte, perm os.FileMode, commit func(tmpPath, path string) error) error {
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+
+	if err := file.Chmod(perm); err != nil && runtime.GOOS != "windows" {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := commit(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes a directory entry change such as a rename to disk. Windows
+// does not support syncing directories, so it is skipped there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
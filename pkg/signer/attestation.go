@@ -0,0 +1,137 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// AttestationsDirname is the data directory subdirectory attestations are
+// kept in, one file per independent block.
+const AttestationsDirname = "attestations"
+
+// Attestation is an independent block together with the exact proxy payload
+// it attests to and the key the proxy block was verified with, so that a
+// dispute can be replayed byte for byte.
+type Attestation struct {
+	IndependentBlock *NVLBlock `json:"independentBlock"`
+	ProxyBlockRaw    string    `json:"proxyBlockRaw"`
+	ProxyPublicKey   string    `json:"proxyPublicKey"`
+}
+
+func NewAttestation(independentBlock, proxyBlock *NVLBlock, proxyPublicKey []byte) *Attestation {
+	return &Attestation{
+		IndependentBlock: independentBlock,
+		ProxyBlockRaw:    proxyBlock.Raw(),
+		ProxyPublicKey:   hex.EncodeToString(proxyPublicKey),
+	}
+}
+
+// Replay verifies the attestation again from the stored payloads: the proxy
+// block against the proxy key, and the independent block against its own key
+// and the proxy block hash.
+func (a *Attestation) Replay() error {
+	proxyBlock, err := ParseNVLBlock([]byte(a.ProxyBlockRaw))
+	if err != nil {
+		return fmt.Errorf("invalid proxy block: %w", err)
+	}
+	if _, err := CheckBlockFormat(proxyBlock); err != nil {
+		return err
+	}
+	proxyHash, err := proxyBlock.Hash()
+	if err != nil {
+		return err
+	}
+	if hash := hex.EncodeToString(proxyHash); hash != proxyBlock.Seal.Proofs {
+		return fmt.Errorf("proxy block contents hash to %s, not %s", hash, proxyBlock.Seal.Proofs)
+	}
+	proxyPublicKey, err := hexutil.Decode("0x" + a.ProxyPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid proxy public key: %w", err)
+	}
+	if valid, err := VerifyBlock(proxyPublicKey, proxyBlock); err != nil {
+		return err
+	} else if !valid {
+		return errors.New("proxy block signature does not match the proxy public key")
+	}
+
+	block := a.IndependentBlock
+	if len(block.Blocks) != 1 || block.Blocks[0] != proxyBlock.Seal.Proofs {
+		return fmt.Errorf("independent block attests %v, not proxy block %s", block.Blocks, proxyBlock.Seal.Proofs)
+	}
+	hash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash) != block.Seal.Proofs {
+		return fmt.Errorf("independent block contents hash to %x, not %s", hash, block.Seal.Proofs)
+	}
+	publicKey, err := hexutil.Decode("0x" + block.Header.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid independent block public key: %w", err)
+	}
+	if valid, err := VerifyBlock(publicKey, block); err != nil {
+		return err
+	} else if !valid {
+		return errors.New("independent block signature does not match its public key")
+	}
+	return nil
+}
+
+func (s *Store) attestationPath(hash string) string {
+	return filepath.Join(s.Dir, AttestationsDirname, hash+".json")
+}
+
+func (s *Store) SaveAttestation(attestation *Attestation) error {
+	if err := os.MkdirAll(filepath.Join(s.Dir, AttestationsDirname), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(attestation, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.attestationPath(attestation.IndependentBlock.Seal.Proofs), data, 0600)
+}
+
+// LoadAttestation returns the attestation stored for an independent block
+// hash.
+func (s *Store) LoadAttestation(hash string) (*Attestation, error) {
+	if !isBlockHash(hash) {
+		return nil, fmt.Errorf("invalid block hash %q", hash)
+	}
+	data, err := os.ReadFile(s.attestationPath(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	attestation := new(Attestation)
+	if err := json.Unmarshal(data, attestation); err != nil {
+		return nil, err
+	}
+	if attestation.IndependentBlock == nil || attestation.IndependentBlock.Header == nil || attestation.IndependentBlock.Seal == nil {
+		return nil, errors.New("attestation has no independent block")
+	}
+	return attestation, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// MinBackupPassphraseLength is the shortest passphrase a backup is encrypted
+// with.
+const MinBackupPassphraseLength = 8
+
+// BackupPassphraseEnv names the environment variable backup passphrases are
+// read from when set, so backups can be scripted.
+const BackupPassphraseEnv = "INDEPENDENT_SIGNER_BACKUP_PASSPHRASE"
+
+// backupFiles are the data directory files a backup holds: the signing key,
+// the record of signed proxy blocks and the configuration. The prior block
+// hash is left out because a backup goes stale as soon as the signer runs
+// again, and continuing from it would fork the independent chain; it is
+// recovered from the NVL Proxy instead. Caches, status and attestations are
+// left out too, they are rebuilt or only kept for audits.
+var backupFiles = []string{
+	SigningKeyFilename,
+	SigningGuardFilename,
+	ConfigFilename,
+}
+
+// scrypt parameters of new backups, as recommended for interactive logins in
+// 2017.
+const (
+	backupScryptN = 1 << 15
+	backupScryptR = 8
+	backupScryptP = 1
+)
+
+// Bounds on the scrypt parameters read from a backup, so that a crafted file
+// cannot make deriving its key take gigabytes of memory or hours of CPU.
+const (
+	maxBackupScryptN      = 1 << 20
+	maxBackupScryptMemory = 256 << 20
+	maxBackupScryptP      = 16
+)
+
+var (
+	// ErrWrongPassphrase is returned when a backup cannot be decrypted.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupt backup")
+	// ErrOtherSigningKey is returned when restoring a backup into a data
+	// directory that already holds a different signing key.
+	ErrOtherSigningKey = errors.New("the data directory already holds a different signing key")
+)
+
+// Backup is an encrypted copy of the signing key and state. The public key is
+// kept in the clear so a backup can be matched to a registered node without
+// the passphrase.
+type Backup struct {
+	Version    int    `json:"version"`
+	PublicKey  string `json:"publicKey"`
+	ScryptN    int    `json:"scryptN"`
+	ScryptR    int    `json:"scryptR"`
+	ScryptP    int    `json:"scryptP"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ReadBackup reads a backup without decrypting it.
+func ReadBackup(path string) (*Backup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	backup := new(Backup)
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("%s is not a signer backup: %w", path, err)
+	}
+	if backup.Version != 1 {
+		return nil, fmt.Errorf("%s is a backup of unsupported version %d", path, backup.Version)
+	}
+	if err := backup.checkScrypt(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return backup, nil
+}
+
+func (b *Backup) checkScrypt() error {
+	n, r, p := b.ScryptN, b.ScryptR, b.ScryptP
+	if n < 2 || n > maxBackupScryptN || n&(n-1) != 0 || r < 1 || p < 1 || p > maxBackupScryptP ||
+		r > maxBackupScryptMemory/(128*n) {
+		return fmt.Errorf("the backup has unsupported scrypt parameters N=%d, r=%d, p=%d", n, r, p)
+	}
+	return nil
+}
+
+// ExportBackup writes the signing key and state, encrypted with passphrase, to
+// path and returns the public key of the backed up signing key.
+func (s *Store) ExportBackup(path, passphrase string) (string, error) {
+	if len(passphrase) < MinBackupPassphraseLength {
+		return "", fmt.Errorf("the backup passphrase must be at least %d characters", MinBackupPassphraseLength)
+	}
+
+	lock, err := s.Lock()
+	if err != nil {
+		return "", fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	signingKey, err := s.LoadSigningKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %w", err)
+	}
+	files := make(map[string][]byte)
+	for _, filename := range backupFiles {
+		data, err := s.readFile(filename)
+		if err != nil {
+			return "", err
+		}
+		if data != nil {
+			files[filename] = data
+		}
+	}
+	plaintext, err := json.Marshal(files)
+	if err != nil {
+		return "", err
+	}
+
+	backup := &Backup{
+		Version:   1,
+		PublicKey: PublicKeyHex(signingKey),
+		ScryptN:   backupScryptN,
+		ScryptR:   backupScryptR,
+		ScryptP:   backupScryptP,
+		Salt:      make([]byte, 32),
+	}
+	if _, err := rand.Read(backup.Salt); err != nil {
+		return "", err
+	}
+	aead, err := backup.cipher(passphrase)
+	if err != nil {
+		return "", err
+	}
+	backup.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(backup.Nonce); err != nil {
+		return "", err
+	}
+	backup.Ciphertext = aead.Seal(nil, backup.Nonce, plaintext, []byte(backup.PublicKey))
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return "", err
+	}
+	return backup.PublicKey, nil
+}
+
+// RestoreBackup decrypts the backup at path into the data directory and
+// returns the public key of the restored signing key. Into an empty data
+// directory every file of the backup is restored, except the prior block hash
+// of older backups: the next run, or Engine.Recover, resumes the independent
+// chain from the NVL Proxy. If the data directory already holds the same
+// signing key, only the signing guard of the backup is merged, so newer local
+// state is never replaced by older state.
+func (s *Store) RestoreBackup(path, passphrase string) (string, error) {
+	backup, err := ReadBackup(path)
+	if err != nil {
+		return "", err
+	}
+	files, err := backup.decrypt(passphrase)
+	if err != nil {
+		return "", err
+	}
+	restoredKey, err := crypto.HexToECDSA(strings.TrimSpace(string(files[SigningKeyFilename])))
+	if err != nil {
+		return "", fmt.Errorf("the backup holds an invalid signing key: %w", err)
+	}
+	if PublicKeyHex(restoredKey) != backup.PublicKey {
+		return "", fmt.Errorf("the backup holds the signing key of %s, not of %s", PublicKeyHex(restoredKey), backup.PublicKey)
+	}
+
+	lock, err := s.Lock()
+	if err != nil {
+		return "", fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	exists, err := s.HasSigningKey()
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		signingKey, err := s.LoadSigningKey()
+		if err != nil {
+			return "", fmt.Errorf("failed to load signing key: %w", err)
+		}
+		if PublicKeyHex(signingKey) != backup.PublicKey {
+			return "", fmt.Errorf("%w %s", ErrOtherSigningKey, PublicKeyHex(signingKey))
+		}
+		if data := files[SigningGuardFilename]; data != nil {
+			if err := s.mergeSigningGuard(data); err != nil {
+				return "", err
+			}
+		}
+		return backup.PublicKey, nil
+	}
+
+	for _, filename := range backupFiles {
+		if data, ok := files[filename]; ok && filename != SigningKeyFilename {
+			if err := WriteFileAtomic(s.path(filename), data, 0600); err != nil {
+				return "", err
+			}
+		}
+	}
+	// The key is written last, so an interrupted restore can be repeated.
+	if err := CreateFileAtomic(s.path(SigningKeyFilename), files[SigningKeyFilename], 0600); err != nil {
+		return "", err
+	}
+	if err := s.SavePublicKey(restoredKey); err != nil {
+		return "", err
+	}
+	return backup.PublicKey, nil
+}
+
+func (s *Store) mergeSigningGuard(data []byte) error {
+	imported := new(SigningGuard)
+	if err := json.Unmarshal(data, imported); err != nil {
+		return fmt.Errorf("the backup holds a corrupt signing guard: %w", err)
+	}
+	if imported.Version != SigningGuardVersion {
+		return fmt.Errorf("the backup holds an unsupported signing guard version %q", imported.Version)
+	}
+	guard, err := s.LoadSigningGuard()
+	if err != nil {
+		return err
+	}
+	guard.Merge(imported)
+	return s.SaveSigningGuard(guard)
+}
+
+func (b *Backup) cipher(passphrase string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), b.Salt, b.ScryptN, b.ScryptR, b.ScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (b *Backup) decrypt(passphrase string) (map[string][]byte, error) {
+	aead, err := b.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := aead.Open(nil, b.Nonce, b.Ciphertext, []byte(b.PublicKey))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	files := make(map[string][]byte)
+	if err := json.Unmarshal(plaintext, &files); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if files[SigningKeyFilename] == nil {
+		return nil, errors.New("the backup holds no signing key")
+	}
+	return files, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+func exportTestBackup(t *testing.T) (string, string) {
+	t.Helper()
+	store := NewStore(t.TempDir())
+	if _, err := store.GenerateSigningKey(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SavePriorBlockHash("0x" + strings.Repeat("ab", 32)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.json")
+	publicKey, err := store.ExportBackup(path, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, publicKey
+}
+
+func rewriteBackup(t *testing.T, path string, change func(*Backup)) {
+	t.Helper()
+	backup, err := ReadBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(backup)
+	data, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreBackupLeavesOutPriorBlockHash(t *testing.T) {
+	path, publicKey := exportTestBackup(t)
+
+	store := NewStore(t.TempDir())
+	restored, err := store.RestoreBackup(path, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != publicKey {
+		t.Errorf("restored %s, want %s", restored, publicKey)
+	}
+	signingKey, err := store.LoadSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PublicKeyHex(signingKey) != publicKey {
+		t.Errorf("restored key is %s, want %s", PublicKeyHex(signingKey), publicKey)
+	}
+	if hash, err := store.LoadPriorBlockHash(); err != nil || hash != "" {
+		t.Errorf("prior block hash is %q, %v, want none", hash, err)
+	}
+
+	if _, err := NewStore(t.TempDir()).RestoreBackup(path, "wrong passphrase"); err != ErrWrongPassphrase {
+		t.Errorf("wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestRestoreBackupChecksPublicKey(t *testing.T) {
+	path, _ := exportTestBackup(t)
+	other, err := NewStore(t.TempDir()).GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-encrypt the backup under the public key of another signing key, as a
+	// crafted backup would be.
+	rewriteBackup(t, path, func(backup *Backup) {
+		files, err := backup.decrypt(testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, err := json.Marshal(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aead, err := backup.cipher(testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backup.PublicKey = PublicKeyHex(other)
+		backup.Ciphertext = aead.Seal(nil, backup.Nonce, plaintext, []byte(backup.PublicKey))
+	})
+
+	store := NewStore(t.TempDir())
+	if _, err := store.RestoreBackup(path, testPassphrase); err == nil {
+		t.Fatal("restored a backup whose key does not match its public key")
+	}
+	if exists, _ := store.HasSigningKey(); exists {
+		t.Error("a signing key was written")
+	}
+}
+
+func TestReadBackupBoundsScrypt(t *testing.T) {
+	tests := []struct{ n, r, p int }{
+		{1 << 30, 8, 1},
+		{1 << 20, 8, 1},
+		{1<<15 + 1, 8, 1},
+		{1 << 15, 0, 1},
+		{1 << 15, 8, 1000},
+	}
+	for _, test := range tests {
+		path, _ := exportTestBackup(t)
+		rewriteBackup(t, path, func(backup *Backup) {
+			backup.ScryptN, backup.ScryptR, backup.ScryptP = test.n, test.r, test.p
+		})
+		if _, err := ReadBackup(path); err == nil {
+			t.Errorf("N=%d, r=%d, p=%d: accepted", test.n, test.r, test.p)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// BlockTypeIndependent is the header type of blocks created by the
+	// independent signer.
+	BlockTypeIndependent = "INDEPENDENT"
+)
+
+type NVLBlockHeader struct {
+	Type        string `json:"type"`
+	PriorBlock  string `json:"priorBlock"`
+	Timestamp   string `json:"timestamp"`
+	PublicKey   string `json:"publicKey"`
+	CoiinSupply string `json:"coiinSupply" datastore:"coiinSupply"`
+}
+
+type NVLBlockSeal struct {
+	Proofs    string `json:"proofs"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type NVLBlock struct {
+	Version string          `json:"version"`
+	Header  *NVLBlockHeader `json:"header"`
+	Blocks  []string        `json:"blocks"`
+	Seal    *NVLBlockSeal   `json:"signature"`
+
+	raw string
+}
+
+// ParseNVLBlock decodes a block as served by the NVL Proxy, keeping the raw
+// payload.
+func ParseNVLBlock(data []byte) (*NVLBlock, error) {
+	block := new(NVLBlock)
+	if err := json.Unmarshal(data, block); err != nil {
+		return nil, err
+	}
+	if block.Header == nil || block.Seal == nil {
+		return nil, fmt.Errorf("block is missing its header or signature")
+	}
+	block.raw = string(data)
+	return block, nil
+}
+
+// Raw returns the payload the block was parsed from, if any.
+func (b *NVLBlock) Raw() string {
+	return b.raw
+}
+
+// MarshalForSigning returns the canonical payload that is hashed and signed,
+// encoded according to the block's format version. For blocks parsed from the
+// NVL Proxy the payload is a canonicalization of the raw JSON, so that every
+// header field the proxy signed is included, even ones this signer does not
+// know.
+func (b *NVLBlock) MarshalForSigning() ([]byte, error) {
+	format, err := LookupBlockFormat(b.Version)
+	if err != nil {
+		return nil, err
+	}
+	if b.raw != "" {
+		return format.CanonicalizeRaw([]byte(b.raw))
+	}
+	return format.MarshalForSigning(b)
+}
+
+// Hash returns the Keccak-256 hash of the canonical signing payload.
+func (b *NVLBlock) Hash() ([]byte, error) {
+	data, err := b.MarshalForSigning()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(data), nil
+}
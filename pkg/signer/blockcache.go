@@ -0,0 +1,289 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// BlocksDirname is the data directory subdirectory fetched proxy blocks
+	// are cached in, one file per block.
+	BlocksDirname = "blocks"
+	// MaxCachedBlocks is how many proxy blocks are kept in the cache. The
+	// blocks fetched longest ago are removed first.
+	MaxCachedBlocks = 10000
+	// MirrorIndexFilename lists the blocks of an exported mirror.
+	MirrorIndexFilename = "index.json"
+)
+
+// CachedBlock is a proxy block exactly as the NVL Proxy served it, together
+// with the result of verifying it.
+type CachedBlock struct {
+	Hash           string    `json:"hash"`
+	Raw            string    `json:"raw"`
+	FetchedAt      time.Time `json:"fetchedAt"`
+	ProxyPublicKey string    `json:"proxyPublicKey,omitempty"`
+	Verified       bool      `json:"verified"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// NewCachedBlock records the result of verifying block against
+// proxyPublicKey. verifyErr is nil if the block verified.
+func NewCachedBlock(block *NVLBlock, proxyPublicKey []byte, verifyErr error) *CachedBlock {
+	cached := &CachedBlock{
+		Hash:           block.Seal.Proofs,
+		Raw:            block.Raw(),
+		FetchedAt:      time.Now().UTC(),
+		ProxyPublicKey: hex.EncodeToString(proxyPublicKey),
+		Verified:       verifyErr == nil,
+	}
+	if verifyErr != nil {
+		cached.Error = verifyErr.Error()
+	}
+	return cached
+}
+
+// Block parses the cached payload.
+func (c *CachedBlock) Block() (*NVLBlock, error) {
+	return ParseNVLBlock([]byte(c.Raw))
+}
+
+// Verify checks the cached payload again: it must hash to the cached hash and
+// be signed by the proxy key it was verified with.
+func (c *CachedBlock) Verify() error {
+	block, err := c.Block()
+	if err != nil {
+		return err
+	}
+	if _, err := CheckBlockFormat(block); err != nil {
+		return err
+	}
+	hash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(hex.EncodeToString(hash), c.Hash) {
+		return fmt.Errorf("contents hash to %x, not %s", hash, c.Hash)
+	}
+	proxyPublicKey, err := hexutil.Decode("0x" + c.ProxyPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid proxy public key: %w", err)
+	}
+	if valid, err := VerifyBlock(proxyPublicKey, block); err != nil {
+		return err
+	} else if !valid {
+		return ErrInvalidProxyBlock
+	}
+	return nil
+}
+
+func (s *Store) cachedBlockPath(hash string) string {
+	return filepath.Join(s.Dir, BlocksDirname, strings.ToLower(hash)+".json")
+}
+
+// SaveCachedBlock adds a block to the cache, removing the oldest blocks once
+// there are more than MaxCachedBlocks.
+func (s *Store) SaveCachedBlock(cached *CachedBlock) error {
+	if !isBlockHash(cached.Hash) {
+		return fmt.Errorf("invalid block hash %q", cached.Hash)
+	}
+	if err := os.MkdirAll(filepath.Join(s.Dir, BlocksDirname), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(s.cachedBlockPath(cached.Hash), data, 0600); err != nil {
+		return err
+	}
+	return s.pruneBlockCache()
+}
+
+// LoadCachedBlock returns the cached block with hash, or nil if it is not
+// cached.
+func (s *Store) LoadCachedBlock(hash string) (*CachedBlock, error) {
+	if !isBlockHash(hash) {
+		return nil, fmt.Errorf("invalid block hash %q", hash)
+	}
+	data, err := os.ReadFile(s.cachedBlockPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cached := new(CachedBlock)
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, fmt.Errorf("cached block %s is corrupt: %w", hash, err)
+	}
+	return cached, nil
+}
+
+// CachedBlocks returns every cached block, most recently fetched first.
+func (s *Store) CachedBlocks() ([]*CachedBlock, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, BlocksDirname))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*CachedBlock, 0, len(entries))
+	for _, entry := range entries {
+		hash, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !isBlockHash(hash) {
+			continue
+		}
+		cached, err := s.LoadCachedBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			blocks = append(blocks, cached)
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].FetchedAt.After(blocks[j].FetchedAt)
+	})
+	return blocks, nil
+}
+
+func (s *Store) pruneBlockCache() error {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, BlocksDirname))
+	if err != nil || len(entries) <= MaxCachedBlocks {
+		return err
+	}
+
+	type cachedFile struct {
+		name    string
+		modTime time.Time
+	}
+	files := make([]cachedFile, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{name: entry.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, file := range files[:len(files)-MaxCachedBlocks] {
+		if err := os.Remove(filepath.Join(s.Dir, BlocksDirname, file.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MirrorEntry describes one block of an exported mirror.
+type MirrorEntry struct {
+	Hash           string `json:"hash"`
+	ProxyPublicKey string `json:"proxyPublicKey"`
+	Verified       bool   `json:"verified"`
+}
+
+// ExportMirror writes every cached block to dir as the raw payload the NVL
+// Proxy served, named <hash>.json, next to an index.json listing the blocks
+// most recently fetched first. It returns the number of blocks written.
+func (s *Store) ExportMirror(dir string) (int, error) {
+	blocks, err := s.CachedBlocks()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	index := struct {
+		Blocks []*MirrorEntry `json:"blocks"`
+	}{Blocks: make([]*MirrorEntry, 0, len(blocks))}
+	for _, cached := range blocks {
+		if err := WriteFileAtomic(filepath.Join(dir, strings.ToLower(cached.Hash)+".json"), []byte(cached.Raw), 0644); err != nil {
+			return 0, err
+		}
+		index.Blocks = append(index.Blocks, &MirrorEntry{
+			Hash:           cached.Hash,
+			ProxyPublicKey: cached.ProxyPublicKey,
+			Verified:       cached.Verified,
+		})
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, MirrorIndexFilename), data, 0644); err != nil {
+		return 0, err
+	}
+	return len(blocks), nil
+}
+
+// BlockCacheReport is the result of checking the block cache.
+type BlockCacheReport struct {
+	// Blocks is the number of cached blocks.
+	Blocks int
+	// Invalid maps the hash of every block that failed verification to the
+	// reason.
+	Invalid map[string]string
+	// Gaps lists cached blocks whose prior block is not cached. The oldest
+	// cached block of the chain is always one of them.
+	Gaps []string
+}
+
+// CheckBlockCache verifies every cached block again and checks which parts of
+// the proxy chain the cache holds.
+func (s *Store) CheckBlockCache() (*BlockCacheReport, error) {
+	blocks, err := s.CachedBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BlockCacheReport{Blocks: len(blocks), Invalid: make(map[string]string)}
+	cached := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		cached[strings.ToLower(block.Hash)] = true
+	}
+	for _, block := range blocks {
+		if err := block.Verify(); err != nil {
+			report.Invalid[block.Hash] = err.Error()
+			continue
+		}
+		parsed, err := block.Block()
+		if err != nil {
+			return nil, err
+		}
+		if prior := parsed.Header.PriorBlock; prior != "" && !cached[strings.ToLower(prior)] {
+			report.Gaps = append(report.Gaps, block.Hash)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// MaxChildCacheEntries is how many verified child blocks are remembered. The
+// oldest entries are forgotten first.
+const MaxChildCacheEntries = 100000
+
+// ErrInvalidChildBlock is returned when a block sealed by a proxy block does
+// not verify.
+var ErrInvalidChildBlock = errors.New("NVL Proxy block seals an invalid child block")
+
+// ChildKeys is the set of public keys child blocks may be signed with, as
+// lowercase hex without a 0x prefix.
+type ChildKeys map[string]bool
+
+// ParseChildKeys parses hex encoded public keys, with or without a 0x prefix.
+func ParseChildKeys(keys []string) (ChildKeys, error) {
+	childKeys := make(ChildKeys, len(keys))
+	for _, key := range keys {
+		key = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(key), "0x"))
+		if key == "" {
+			continue
+		}
+		if _, err := hexutil.Decode("0x" + key); err != nil {
+			return nil, fmt.Errorf("invalid child public key %q: %w", key, err)
+		}
+		childKeys[key] = true
+	}
+	return childKeys, nil
+}
+
+// fingerprint identifies the set, so that children verified against another
+// set are verified again.
+func (k ChildKeys) fingerprint() string {
+	keys := make([]string, 0, len(k))
+	for key := range k {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.Sum256([]byte(strings.Join(keys, ",")))
+	return hex.EncodeToString(hash[:])
+}
+
+// ChildCache remembers the child blocks that already verified, so that deep
+// verification only fetches each child once.
+type ChildCache struct {
+	// TrustedKeys identifies the ChildKeys the children were verified
+	// against.
+	TrustedKeys string               `json:"trustedKeys,omitempty"`
+	Verified    map[string]time.Time `json:"verified"`
+}
+
+// NewChildCache returns an empty cache.
+func NewChildCache() *ChildCache {
+	return &ChildCache{Verified: make(map[string]time.Time)}
+}
+
+// Add records hash as verified at now, forgetting the oldest entries beyond
+// MaxChildCacheEntries.
+func (c *ChildCache) Add(hash string, now time.Time) {
+	c.Verified[hash] = now
+	if len(c.Verified) <= MaxChildCacheEntries {
+		return
+	}
+
+	hashes := make([]string, 0, len(c.Verified))
+	for cached := range c.Verified {
+		hashes = append(hashes, cached)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return c.Verified[hashes[i]].Before(c.Verified[hashes[j]])
+	})
+	for _, cached := range hashes[:len(hashes)-MaxChildCacheEntries] {
+		delete(c.Verified, cached)
+	}
+}
+
+// VerifyChildBlock checks that block is the block listed as hash by a proxy
+// block: its contents must hash to hash and it must be signed by the public
+// key in its header, which must be one of trusted. A block signed by any other
+// key is refused, since anyone can sign a block with a key of their own.
+func VerifyChildBlock(hash string, block *NVLBlock, trusted ChildKeys) error {
+	if _, err := CheckBlockFormat(block); err != nil {
+		return err
+	}
+
+	blockHash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(hex.EncodeToString(blockHash), hash) {
+		return fmt.Errorf("contents hash to %x, not %s", blockHash, hash)
+	}
+	if !strings.EqualFold(block.Seal.Proofs, hash) {
+		return fmt.Errorf("seal names hash %s, not %s", block.Seal.Proofs, hash)
+	}
+
+	if !trusted[strings.ToLower(strings.TrimPrefix(block.Header.PublicKey, "0x"))] {
+		return fmt.Errorf("signed by public key %s, which is not trusted", block.Header.PublicKey)
+	}
+	publicKey, err := hexutil.Decode("0x" + strings.TrimPrefix(block.Header.PublicKey, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if valid, err := VerifyBlock(publicKey, block); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	} else if !valid {
+		return errors.New("signature does not match the public key in its header")
+	}
+	return nil
+}
+
+// verifyChildren fetches and verifies every child of proxyBlock that is not
+// cached yet. At most MaxChildrenPerRun children are fetched; if more remain,
+// the ones verified are cached and an error is returned so the proxy block is
+// only signed once a later run has verified the rest.
+func (e *Engine) verifyChildren(ctx context.Context, proxyBlock *NVLBlock) error {
+	trusted, err := ParseChildKeys(e.Config.ChildKeys)
+	if err != nil {
+		return err
+	} else if len(trusted) == 0 {
+		return errors.New("child blocks cannot be verified without the public keys they are signed with")
+	}
+
+	cache, err := e.Store.LoadChildCache()
+	if err != nil {
+		return fmt.Errorf("failed to load verified child blocks: %w", err)
+	}
+	if cache.TrustedKeys != trusted.fingerprint() {
+		cache = NewChildCache()
+		cache.TrustedKeys = trusted.fingerprint()
+	}
+
+	pending := make([]string, 0, len(proxyBlock.Blocks))
+	for _, hash := range proxyBlock.Blocks {
+		if _, ok := cache.Verified[strings.ToLower(hash)]; !ok {
+			pending = append(pending, hash)
+		}
+	}
+	e.Log.Printf("Verifying %d of %d child blocks\n", len(pending), len(proxyBlock.Blocks))
+
+	limit := len(pending)
+	if e.Config.MaxChildrenPerRun > 0 && limit > e.Config.MaxChildrenPerRun {
+		limit = e.Config.MaxChildrenPerRun
+	}
+
+	var verifyErr error
+	verified := 0
+	for _, hash := range pending[:limit] {
+		child, err := e.Archive.FetchBlock(ctx, hash)
+		if err != nil {
+			verifyErr = fmt.Errorf("failed to fetch child block %s: %w", hash, err)
+			break
+		}
+		if err := VerifyChildBlock(hash, child, trusted); err != nil {
+			e.raiseAlert(ctx, fmt.Sprintf("NVL Proxy block %s seals child block %s that failed verification: %s", proxyBlock.Seal.Proofs, hash, err))
+			verifyErr = fmt.Errorf("%w: child block %s: %s", ErrInvalidChildBlock, hash, err)
+			break
+		}
+		cache.Add(strings.ToLower(hash), time.Now().UTC())
+		verified++
+	}
+
+	if verified > 0 {
+		if err := e.Store.SaveChildCache(cache); err != nil {
+			e.Log.Printf("failed to save verified child blocks: %s", err)
+		}
+	}
+	if verifyErr != nil {
+		return verifyErr
+	}
+	if remaining := len(pending) - verified; remaining > 0 {
+		return fmt.Errorf("%d child blocks are still unverified, they will be verified in the next runs", remaining)
+	}
+	return nil
+}
+
+// LoadChildCache returns the verified child blocks, or an empty cache if none
+// were saved.
+func (s *Store) LoadChildCache() (*ChildCache, error) {
+	fileData, err := os.ReadFile(s.path(ChildCacheFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return NewChildCache(), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cache := NewChildCache()
+	if err := json.Unmarshal(fileData, cache); err != nil {
+		return nil, err
+	}
+	if cache.Verified == nil {
+		cache.Verified = make(map[string]time.Time)
+	}
+	return cache, nil
+}
+
+func (s *Store) SaveChildCache(cache *ChildCache) error {
+	return s.writeJSON(ChildCacheFilename, cache)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func signedChildBlock(t *testing.T) (string, *NVLBlock, string) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &NVLBlock{
+		Version: "1",
+		Header: &NVLBlockHeader{
+			Type:       "L1",
+			PriorBlock: "",
+			Timestamp:  "1700000000",
+			PublicKey:  PublicKeyHex(key),
+		},
+		Blocks: []string{},
+		Seal:   &NVLBlockSeal{},
+	}
+	hash, signature, err := SignBlock(key, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Seal.Proofs, block.Seal.Signature = hash, signature
+	return hash, block, PublicKeyHex(key)
+}
+
+func TestVerifyChildBlock(t *testing.T) {
+	hash, block, publicKey := signedChildBlock(t)
+	_, _, otherKey := signedChildBlock(t)
+
+	trusted, err := ParseChildKeys([]string{otherKey, "0x" + strings.ToUpper(publicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChildBlock(hash, block, trusted); err != nil {
+		t.Errorf("trusted child: %v", err)
+	}
+
+	untrusted, err := ParseChildKeys([]string{otherKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChildBlock(hash, block, untrusted); err == nil {
+		t.Error("accepted a child signed by an untrusted key")
+	}
+
+	block.Header.Timestamp = "1700000001"
+	if err := VerifyChildBlock(hash, block, trusted); err == nil {
+		t.Error("accepted a child whose contents do not match its hash")
+	}
+
+	if _, err := ParseChildKeys([]string{"not hex"}); err == nil {
+		t.Error("parsed an invalid key")
+	}
+}
+
+func TestChildKeysFingerprint(t *testing.T) {
+	a, _ := ParseChildKeys([]string{"04aa", "04bb"})
+	b, _ := ParseChildKeys([]string{"04BB", "0x04aa"})
+	c, _ := ParseChildKeys([]string{"04aa"})
+	if a.fingerprint() != b.fingerprint() {
+		t.Error("the same keys have different fingerprints")
+	}
+	if a.fingerprint() == c.fingerprint() {
+		t.Error("different keys have the same fingerprint")
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Client talks to the NVL Proxy API. Requests go to the first healthy
+// endpoint in BaseURLs and fail over to the next one when an endpoint cannot
+// be reached or answers with a server error.
+type Client struct {
+	BaseURLs []string
+	HTTP     *http.Client
+
+	mu     sync.Mutex
+	health map[string]*EndpointHealth
+}
+
+// EndpointHealth is the result of the last request made to an endpoint.
+type EndpointHealth struct {
+	URL       string    `json:"url"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"lastError,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	return NewFailoverClient([]string{baseURL}, httpClient)
+}
+
+// NewFailoverClient returns a client that fails over between baseURLs, in
+// order of preference.
+func NewFailoverClient(baseURLs []string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURLs: baseURLs, HTTP: httpClient, health: make(map[string]*EndpointHealth)}
+}
+
+// Health returns what is known about each endpoint, in order of preference.
+// Endpoints that have not been used yet are left out.
+func (c *Client) Health() []*EndpointHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	health := make([]*EndpointHealth, 0, len(c.BaseURLs))
+	for _, baseURL := range c.BaseURLs {
+		if endpoint, ok := c.health[baseURL]; ok {
+			copied := *endpoint
+			health = append(health, &copied)
+		}
+	}
+	return health
+}
+
+// SetHealth seeds the endpoint health, usually with the result of a previous
+// run, so that endpoints known to be down are tried last.
+func (c *Client) SetHealth(health []*EndpointHealth) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, endpoint := range health {
+		copied := *endpoint
+		c.health[endpoint.URL] = &copied
+	}
+}
+
+// CheckHealth asks every endpoint for its status and returns the result, for
+// reporting the health of endpoints between runs.
+func (c *Client) CheckHealth(ctx context.Context) []*EndpointHealth {
+	for _, baseURL := range c.BaseURLs {
+		_, err := c.getFrom(ctx, baseURL, "/api/v1/status")
+		c.observe(baseURL, err)
+	}
+	return c.Health()
+}
+
+// endpoints returns the base URLs to try, healthy ones first.
+func (c *Client) endpoints() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoints := make([]string, 0, len(c.BaseURLs))
+	var unhealthy []string
+	for _, baseURL := range c.BaseURLs {
+		if endpoint, ok := c.health[baseURL]; ok && !endpoint.Healthy {
+			unhealthy = append(unhealthy, baseURL)
+			continue
+		}
+		endpoints = append(endpoints, baseURL)
+	}
+	return append(endpoints, unhealthy...)
+}
+
+func (c *Client) observe(baseURL string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoint := &EndpointHealth{URL: baseURL, Healthy: true, CheckedAt: time.Now().UTC()}
+	if err != nil && isEndpointFailure(err) {
+		endpoint.Healthy = false
+		endpoint.LastError = err.Error()
+	}
+	c.health[baseURL] = endpoint
+}
+
+// isEndpointFailure reports whether err means the endpoint is unavailable, as
+// opposed to having answered a request it could not fulfil.
+func isEndpointFailure(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// requestSentError wraps the error of a request the endpoint may have
+// received, which failover does not repeat on another endpoint.
+type requestSentError struct {
+	err error
+}
+
+func (e *requestSentError) Error() string { return e.err.Error() }
+func (e *requestSentError) Unwrap() error { return e.err }
+
+// failover calls request with each endpoint in turn until one answers without
+// an endpoint failure, or fails after it may have been received.
+func (c *Client) failover(ctx context.Context, request func(baseURL string) error) error {
+	endpoints := c.endpoints()
+	if len(endpoints) == 0 {
+		return errors.New("no NVL Proxy endpoints are configured")
+	}
+
+	var err error
+	for _, baseURL := range endpoints {
+		err = request(baseURL)
+		c.observe(baseURL, err)
+		var sent *requestSentError
+		if err == nil || !isEndpointFailure(err) || ctx.Err() != nil || errors.As(err, &sent) {
+			return err
+		}
+	}
+	if len(endpoints) > 1 {
+		return fmt.Errorf("every NVL Proxy endpoint failed, last error: %w", err)
+	}
+	return err
+}
+
+// FetchVerifyingKey returns the public key the NVL Proxy signs its blocks with.
+func (c *Client) FetchVerifyingKey(ctx context.Context) ([]byte, error) {
+	body, err := c.get(ctx, "/api/v1/status")
+	if err != nil {
+		return nil, err
+	}
+	return parseVerifyingKey(body)
+}
+
+func parseVerifyingKey(body []byte) ([]byte, error) {
+	status := &struct {
+		PublicKey string `json:"publicKey"`
+	}{}
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, err
+	}
+
+	return hexutil.Decode("0x" + status.PublicKey)
+}
+
+// FetchLatestBlock returns the latest proxy block, or nil if the proxy has no
+// blocks.
+func (c *Client) FetchLatestBlock(ctx context.Context) (*NVLBlock, error) {
+	blockHash, err := c.FetchLatestBlockHash(ctx)
+	if err != nil || blockHash == "" {
+		return nil, err
+	}
+	return c.FetchBlock(ctx, blockHash)
+}
+
+// FetchLatestBlockHash returns the hash of the latest proxy block, or an empty
+// string if the proxy has no blocks.
+func (c *Client) FetchLatestBlockHash(ctx context.Context) (string, error) {
+	return c.fetchLatestBlockHash(ctx, "/api/v1/blocks?size=1")
+}
+
+// FetchLatestIndependentBlockHash returns the hash of the most recent
+// independent block the proxy holds for publicKey, or an empty string if it
+// holds none.
+func (c *Client) FetchLatestIndependentBlockHash(ctx context.Context, publicKey string) (string, error) {
+	return c.fetchLatestBlockHash(ctx, "/api/v1/independent/blocks?size=1&publicKey="+url.QueryEscape(publicKey))
+}
+
+func (c *Client) FetchBlock(ctx context.Context, blockHash string) (*NVLBlock, error) {
+	body, err := c.get(ctx, "/api/v1/blocks/"+url.PathEscape(blockHash)+"?raw=true")
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ParseNVLBlock(body)
+	if err != nil {
+		return nil, fmt.Errorf("NVL returned an invalid block for %s: %w", blockHash, err)
+	}
+	return block, nil
+}
+
+// fetchLatestBlockHash returns the hash of the first block listed at path,
+// or an empty string if the list is empty.
+func (c *Client) fetchLatestBlockHash(ctx context.Context, path string) (string, error) {
+	body, err := c.get(ctx, path)
+	if isNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return parseLatestBlockHash(body)
+}
+
+func parseLatestBlockHash(body []byte) (string, error) {
+	blocks := &struct {
+		Blocks []struct {
+			Hash string `json:"hash"`
+		}
+	}{}
+	if err := json.Unmarshal(body, blocks); err != nil {
+		return "", err
+	}
+
+	if len(blocks.Blocks) != 1 {
+		return "", nil
+	}
+
+	return blocks.Blocks[0].Hash, nil
+}
+
+// ErrEndpointsDisagree is returned when NVL Proxy endpoints report different
+// verifying keys or latest blocks, which means the proxy has split or an
+// endpoint is compromised.
+var ErrEndpointsDisagree = errors.New("NVL Proxy endpoints disagree")
+
+// ProxyHead is the verifying key and latest block hash reported by an
+// endpoint.
+type ProxyHead struct {
+	URL             string
+	VerifyingKey    []byte
+	LatestBlockHash string
+}
+
+// FetchConsistentHead asks endpoints, healthy ones first, for their verifying
+// key and latest block hash until quorum of them have answered. It returns
+// the head they agree on, or an error wrapping ErrEndpointsDisagree if any of
+// them differ. A block produced between two of the requests also looks like a
+// disagreement, so callers should simply try again later.
+func (c *Client) FetchConsistentHead(ctx context.Context, quorum int) (*ProxyHead, error) {
+	var heads []*ProxyHead
+	var lastErr error
+	for _, baseURL := range c.endpoints() {
+		head, err := c.fetchHead(ctx, baseURL)
+		c.observe(baseURL, err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, other := range heads {
+			if !bytes.Equal(head.VerifyingKey, other.VerifyingKey) {
+				return nil, fmt.Errorf("%w: %s reports verifying key %x but %s reports %x",
+					ErrEndpointsDisagree, other.URL, other.VerifyingKey, head.URL, head.VerifyingKey)
+			}
+			if head.LatestBlockHash != other.LatestBlockHash {
+				return nil, fmt.Errorf("%w: %s reports latest block %q but %s reports %q",
+					ErrEndpointsDisagree, other.URL, other.LatestBlockHash, head.URL, head.LatestBlockHash)
+			}
+		}
+		heads = append(heads, head)
+		if len(heads) == quorum {
+			return heads[0], nil
+		}
+	}
+
+	err := fmt.Errorf("only %d of the %d NVL Proxy endpoints needed for a consistent view answered", len(heads), quorum)
+	if lastErr != nil {
+		err = fmt.Errorf("%s, last error: %w", err, lastErr)
+	}
+	return nil, err
+}
+
+func (c *Client) fetchHead(ctx context.Context, baseURL string) (*ProxyHead, error) {
+	body, err := c.getFrom(ctx, baseURL, "/api/v1/status")
+	if err != nil {
+		return nil, err
+	}
+	verifyingKey, err := parseVerifyingKey(body)
+	if err != nil {
+		return nil, err
+	}
+
+	head := &ProxyHead{URL: baseURL, VerifyingKey: verifyingKey}
+	body, err = c.getFrom(ctx, baseURL, "/api/v1/blocks?size=1")
+	if isNotFound(err) {
+		return head, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if head.LatestBlockHash, err = parseLatestBlockHash(body); err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// PostIndependentBlock enqueues a signed independent block. It returns the
+// response status code and, for unsuccessful requests, the response body with
+// a StatusError. Enqueuing is not idempotent, so the next endpoint is only
+// tried when the request could not be sent to the previous one.
+func (c *Client) PostIndependentBlock(ctx context.Context, block *NVLBlock, signerVersion string) (int, string, error) {
+	body := struct {
+		Version                  string    `json:"version"`
+		Block                    *NVLBlock `json:"block"`
+		IndependentSignerVersion string    `json:"independentSignerVersion"`
+	}{
+		Version:                  "1",
+		Block:                    block,
+		IndependentSignerVersion: signerVersion,
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var statusCode int
+	var respBody string
+	err = c.failover(ctx, func(baseURL string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v1/independent/enqueue", bytes.NewReader(jsonBody))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var sent bool
+		req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: func(httptrace.WroteRequestInfo) { sent = true },
+		}))
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			if sent {
+				return &requestSentError{err}
+			}
+			return err
+		}
+		defer resp.Body.Close()
+
+		statusCode, respBody = resp.StatusCode, ""
+		if resp.StatusCode > 299 {
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return &requestSentError{err}
+			}
+			respBody = string(body)
+			return &requestSentError{&StatusError{StatusCode: resp.StatusCode}}
+		}
+		return nil
+	})
+	return statusCode, respBody, err
+}
+
+// StatusError is returned when the NVL Proxy answers with an unexpected
+// status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("NVL returned non 200 status code: Status %d", e.StatusCode)
+}
+
+func isNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	var body []byte
+	err := c.failover(ctx, func(baseURL string) error {
+		var err error
+		body, err = c.getFrom(ctx, baseURL, path)
+		return err
+	})
+	return body, err
+}
+
+func (c *Client) getFrom(ctx context.Context, baseURL, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func enqueueServer(t *testing.T, status int, posts *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(posts, 1)
+		w.WriteHeader(status)
+		w.Write([]byte("answer"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPostIndependentBlockFailover(t *testing.T) {
+	block := &NVLBlock{Header: &NVLBlockHeader{}, Seal: &NVLBlockSeal{}}
+
+	t.Run("accepted", func(t *testing.T) {
+		var posts int32
+		client := NewFailoverClient([]string{enqueueServer(t, http.StatusCreated, &posts).URL}, http.DefaultClient)
+		statusCode, _, err := client.PostIndependentBlock(context.Background(), block, "test")
+		if err != nil || statusCode != http.StatusCreated {
+			t.Errorf("got %d, %v", statusCode, err)
+		}
+	})
+
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusTooManyRequests, http.StatusForbidden} {
+		var primary, secondary int32
+		client := NewFailoverClient([]string{
+			enqueueServer(t, status, &primary).URL,
+			enqueueServer(t, http.StatusCreated, &secondary).URL,
+		}, http.DefaultClient)
+		statusCode, body, err := client.PostIndependentBlock(context.Background(), block, "test")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != status || statusCode != status || body != "answer" {
+			t.Errorf("status %d: got %d, %q, %v", status, statusCode, body, err)
+		}
+		if primary != 1 || secondary != 0 {
+			t.Errorf("status %d: posted %d times to the primary and %d times to the secondary, want once to the primary only", status, primary, secondary)
+		}
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		var unreachable, secondary int32
+		down := enqueueServer(t, http.StatusCreated, &unreachable)
+		down.Close()
+		client := NewFailoverClient([]string{down.URL, enqueueServer(t, http.StatusCreated, &secondary).URL}, http.DefaultClient)
+		statusCode, _, err := client.PostIndependentBlock(context.Background(), block, "test")
+		if err != nil || statusCode != http.StatusCreated || secondary != 1 {
+			t.Errorf("got %d, %v after %d posts to the secondary", statusCode, err, secondary)
+		}
+	})
+}
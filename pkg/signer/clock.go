@@ -0,0 +1,105 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrClockSkew is returned when the local clock is too far from the NVL Proxy
+// clock to produce blocks the proxy will accept.
+var ErrClockSkew = errors.New("local clock is too far from the NVL Proxy clock")
+
+// ClockEstimate tracks how far the NVL Proxy clock is ahead of the local
+// clock. A negative offset means the local clock is ahead.
+type ClockEstimate struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	rtt     time.Duration
+	samples int
+}
+
+// ObserveResponse records the offset implied by an HTTP Date header. The
+// header only has second resolution, so the sample with the shortest round
+// trip is kept.
+func (c *ClockEstimate) ObserveResponse(resp *http.Response, sent, received time.Time) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	rtt := received.Sub(sent)
+	midpoint := sent.Add(rtt / 2)
+	offset := date.Add(500 * time.Millisecond).Sub(midpoint)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 || rtt < c.rtt {
+		c.offset = offset
+		c.rtt = rtt
+	}
+	c.samples++
+}
+
+// ObserveBlockTimestamp uses a proxy block timestamp as a lower bound: a block
+// the proxy already produced cannot be in the future, so a timestamp ahead of
+// the local clock means the local clock is behind by at least that much.
+func (c *ClockEstimate) ObserveBlockTimestamp(timestamp, now time.Time) {
+	ahead := timestamp.Sub(now)
+	if ahead <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 || c.offset < ahead {
+		c.offset = ahead
+	}
+	c.samples++
+}
+
+// Offset returns the estimated offset and whether any samples were taken.
+func (c *ClockEstimate) Offset() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset, c.samples > 0
+}
+
+// Transport wraps next so that the Date header of every response is fed into
+// the estimate.
+func (c *ClockEstimate) Transport(next http.RoundTripper) http.RoundTripper {
+	return &clockObservingTransport{clock: c, next: next}
+}
+
+type clockObservingTransport struct {
+	clock *ClockEstimate
+	next  http.RoundTripper
+}
+
+func (t *clockObservingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.clock.ObserveResponse(resp, sent, time.Now())
+	return resp, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// PublicKeyHex returns the lower case hex encoding of the uncompressed public
+// key, as registered in the Coiin Console.
+func PublicKeyHex(signingKey *ecdsa.PrivateKey) string {
+	return strings.ToLower(hex.EncodeToString(crypto.FromECDSAPub(signingKey.Public().(*ecdsa.PublicKey))))
+}
+
+// VerifyBlock checks that block was signed by publicKey.
+func VerifyBlock(publicKey []byte, block *NVLBlock) (bool, error) {
+	sig, err := hexutil.Decode("0x" + block.Seal.Signature)
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != 65 {
+		return false, fmt.Errorf("signature is %d bytes, expected 65", len(sig))
+	}
+	hash, err := block.Hash()
+	if err != nil {
+		return false, err
+	}
+	return crypto.VerifySignature(publicKey, hash, sig[:len(sig)-1]), nil
+}
+
+// SignBlock signs block with signingKey and returns the hex encoded hash and
+// signature to put in its seal.
+func SignBlock(signingKey *ecdsa.PrivateKey, block *NVLBlock) (string, string, error) {
+	hash, err := block.Hash()
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := crypto.Sign(hash, signingKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("%064x", hash), fmt.Sprintf("%0130x", signature), nil
+}
+
+// NewIndependentBlock creates the unsigned independent block attesting to
+// proxyBlock, chained onto priorHash.
+func NewIndependentBlock(signingKey *ecdsa.PrivateKey, proxyBlock *NVLBlock, priorHash string, now time.Time) *NVLBlock {
+	return &NVLBlock{
+		Version: CurrentBlockVersion,
+		Header: &NVLBlockHeader{
+			Type:        BlockTypeIndependent,
+			PriorBlock:  priorHash,
+			Timestamp:   fmt.Sprintf("%d", now.Unix()),
+			PublicKey:   PublicKeyHex(signingKey),
+			CoiinSupply: proxyBlock.Header.CoiinSupply,
+		},
+		Blocks: []string{proxyBlock.Seal.Proofs},
+		Seal:   &NVLBlockSeal{},
+	}
+}
+
+// VerifyOwnIndependentBlock checks that block is an independent block with the
+// given hash that was signed by signingKey.
+func VerifyOwnIndependentBlock(signingKey *ecdsa.PrivateKey, blockHash string, block *NVLBlock) error {
+	if block.Header.Type != BlockTypeIndependent {
+		return fmt.Errorf("block type is %q, not %s", block.Header.Type, BlockTypeIndependent)
+	}
+	if block.Header.PublicKey != PublicKeyHex(signingKey) {
+		return fmt.Errorf("block was created by a different public key %s", block.Header.PublicKey)
+	}
+	if len(block.Blocks) != 1 {
+		return fmt.Errorf("block attests %d proxy blocks, expected 1", len(block.Blocks))
+	}
+
+	hashBytes, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if hash := fmt.Sprintf("%064x", hashBytes); hash != blockHash || hash != block.Seal.Proofs {
+		return fmt.Errorf("block contents hash to %s", hash)
+	}
+
+	valid, err := VerifyBlock(crypto.FromECDSAPub(&signingKey.PublicKey), block)
+	if err != nil {
+		return err
+	} else if !valid {
+		return fmt.Errorf("block signature was not made by this key")
+	}
+	return nil
+}
+
+func isBlockHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,505 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package signer implements the NVL independent signer: it fetches the latest
+// NVL Proxy block, verifies it, and signs and posts an independent block
+// attesting to it, chaining each independent block onto the previous one.
+//
+// Engine.RunOnce performs one such round; the independent-signer command runs
+// it once per invocation, but it can equally be driven from another service.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidProxyBlock is returned when the latest proxy block was not signed
+// by the proxy's verifying key.
+var ErrInvalidProxyBlock = errors.New("NVL Proxy block failed validation")
+
+// ErrAlreadySigned is returned by RunOnce when the latest proxy block was
+// already signed by the key, so signing it was refused. It is not a failure:
+// the proxy has not produced a new block since, or a block this signer posted
+// was not accepted.
+var ErrAlreadySigned = errors.New("NVL Proxy block was already signed by this key")
+
+// Config controls how an Engine talks to the NVL Proxy and which checks it
+// performs before signing.
+type Config struct {
+	// BaseURLs are the NVL Proxy API hosts, in order of preference. Requests
+	// fail over to the next host when one is unavailable.
+	BaseURLs []string
+	// ConsistencyQuorum is how many hosts must agree on the verifying key and
+	// latest block before signing. Values below 2 disable the check.
+	ConsistencyQuorum int
+	// SignerVersion is reported to the proxy with every independent block.
+	SignerVersion string
+
+	// MaxFutureSkew is how far ahead of the local clock a proxy block
+	// timestamp may be.
+	MaxFutureSkew time.Duration
+	// MaxBlockAge is the maximum age of a proxy block that will be signed.
+	MaxBlockAge time.Duration
+	// WarnClockSkew is the clock offset against the proxy above which a
+	// warning is logged.
+	WarnClockSkew time.Duration
+	// MaxClockSkew is the clock offset against the proxy above which signing
+	// is refused.
+	MaxClockSkew time.Duration
+
+	// RecoverState resumes the independent chain from the proxy when the
+	// prior block hash is missing, or after a block was posted without the
+	// proxy accepting it.
+	RecoverState bool
+
+	// CheckSupply enables the coiinSupply invariants.
+	CheckSupply bool
+	// MaxSupplyIncreasePerHour bounds how fast coiinSupply may grow between
+	// attested proxy blocks, per hour of proxy time. Nil disables the bound.
+	MaxSupplyIncreasePerHour *big.Int
+
+	// VerifyChildren fetches and verifies every child block a proxy block
+	// seals before signing it.
+	VerifyChildren bool
+	// ChildArchiveURL is where child blocks are fetched from. The NVL Proxy is
+	// used when it is empty.
+	ChildArchiveURL string
+	// ChildKeys are the hex encoded public keys child blocks must be signed
+	// with. VerifyChildren needs at least one.
+	ChildKeys []string
+	// MaxChildrenPerRun caps how many child blocks are fetched in one run.
+	// Zero means no cap.
+	MaxChildrenPerRun int
+
+	// AlertURL, if set, receives a JSON POST for every alert raised.
+	AlertURL string
+
+	// RegistrationURL is the host nodes are registered with. The NVL Proxy is
+	// used when it is empty.
+	RegistrationURL string
+
+	// TLS configures the HTTP proxy, certificate authorities and client
+	// certificate used for every request. Its pins are only checked for the
+	// NVL Proxy.
+	TLS TLSConfig
+}
+
+// DefaultConfig returns the configuration used by the independent-signer
+// command when no flags are given.
+func DefaultConfig() Config {
+	return Config{
+		BaseURLs:      []string{"https://nvl.api.coiin.ai"},
+		MaxFutureSkew: 5 * time.Minute,
+		MaxBlockAge:   6 * time.Hour,
+		WarnClockSkew: time.Minute,
+		MaxClockSkew:  5 * time.Minute,
+		RecoverState:  true,
+		CheckSupply:   true,
+
+		MaxChildrenPerRun: 100,
+	}
+}
+
+// Engine signs NVL Proxy blocks with the key kept in its Store.
+type Engine struct {
+	Config Config
+	Store  *Store
+	Client *Client
+	// Archive serves the child blocks sealed by proxy blocks.
+	Archive *Client
+	// Registry registers the public key of the signer.
+	Registry *Client
+	// HTTP is used for requests outside the NVL Proxy API, such as alerts.
+	HTTP  *http.Client
+	Clock *ClockEstimate
+	Log   *log.Logger
+}
+
+// NewEngine returns an engine whose NVL Proxy client feeds the clock skew
+// estimate. It fails if the TLS configuration cannot be loaded.
+func NewEngine(config Config, store *Store) (*Engine, error) {
+	proxyTransport, err := NewTransport(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	// The pins are for the NVL Proxy only: alerts, child archives,
+	// registration and updates are served by other hosts.
+	unpinned := config.TLS
+	unpinned.PinnedSPKI = nil
+	transport, err := NewTransport(unpinned)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := new(ClockEstimate)
+	httpClient := &http.Client{Transport: transport}
+	client := NewFailoverClient(config.BaseURLs, &http.Client{Transport: clock.Transport(proxyTransport)})
+	archive := client
+	if config.ChildArchiveURL != "" {
+		archive = NewClient(config.ChildArchiveURL, httpClient)
+	}
+	registry := client
+	if config.RegistrationURL != "" {
+		registry = NewClient(config.RegistrationURL, httpClient)
+	}
+	return &Engine{
+		Config:   config,
+		Store:    store,
+		Client:   client,
+		Archive:  archive,
+		Registry: registry,
+		HTTP:     httpClient,
+		Clock:    clock,
+		Log:      log.Default(),
+	}, nil
+}
+
+// RunResult describes what a call to RunOnce did.
+type RunResult struct {
+	// ProxyBlock is the latest proxy block, or nil if the proxy had none.
+	ProxyBlock *NVLBlock
+	// IndependentBlock is the block that was signed and posted, if any.
+	IndependentBlock *NVLBlock
+	// AlreadySigned is set when the latest proxy block had already been
+	// signed by this key, in which case nothing was signed and RunOnce
+	// returns ErrAlreadySigned.
+	AlreadySigned *GuardAttestation
+	// StatusCode is the NVL Proxy response to the posted block.
+	StatusCode int
+}
+
+// RunOnce fetches and verifies the latest proxy block and, if it passes every
+// check, signs an independent block over it and posts it to the proxy.
+func (e *Engine) RunOnce(ctx context.Context) (result *RunResult, err error) {
+	lock, err := e.Store.Lock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+	defer func() {
+		e.recordRun(result, err)
+	}()
+
+	if err := e.Store.Check(); err != nil {
+		return nil, fmt.Errorf("data directory check failed: %w", err)
+	}
+
+	if status, err := e.Store.LoadStatus(); err == nil {
+		e.Client.SetHealth(status.Endpoints)
+	}
+	defer e.updateStatus(func(status *Status) {
+		status.Endpoints = e.Client.Health()
+	})
+
+	signingKey, err := e.loadSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	publicKey := PublicKeyHex(signingKey)
+
+	verifyingKey, proxyBlockHash, err := e.fetchHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if proxyBlockHash == "" {
+		e.Log.Println("NVL did not return any blocks to sign")
+		return &RunResult{}, nil
+	}
+	proxyBlock, cached, err := e.fetchProxyBlock(ctx, proxyBlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch NVL block: %w", err)
+	}
+	result = &RunResult{ProxyBlock: proxyBlock}
+	e.Log.Printf("Latest NVL Proxy Block hash: %s\n", proxyBlock.Seal.Proofs)
+
+	err = e.verifyProxyBlock(verifyingKey, proxyBlock)
+	if !cached || err != nil {
+		if err := e.Store.SaveCachedBlock(NewCachedBlock(proxyBlock, verifyingKey, err)); err != nil {
+			e.Log.Printf("failed to cache NVL Proxy block: %s", err)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	e.Log.Println("NVL Proxy block passed verification")
+
+	if timestamp, err := ParseBlockTimestamp(proxyBlock.Header.Timestamp); err == nil {
+		e.Clock.ObserveBlockTimestamp(timestamp, time.Now())
+	}
+	skewErr := e.checkClockSkew()
+	e.updateStatus(func(status *Status) {
+		status.ClockOffsetSeconds = nil
+		if offset, ok := e.Clock.Offset(); ok {
+			seconds := offset.Seconds()
+			status.ClockOffsetSeconds = &seconds
+		}
+	})
+	if skewErr != nil {
+		return result, fmt.Errorf("refusing to sign NVL Proxy block: %w", skewErr)
+	}
+
+	guard, err := e.Store.LoadSigningGuard()
+	if err != nil {
+		return result, fmt.Errorf("failed to load signing guard: %w", err)
+	}
+
+	e.Log.Println("Loading prior block hash")
+	priorBlockHash, err := e.Store.LoadPriorBlockHash()
+	if err != nil {
+		return result, fmt.Errorf("failed to load prior block hash: %w", err)
+	}
+	unconfirmed, err := e.Store.LoadUnconfirmedBlock()
+	if err != nil {
+		return result, fmt.Errorf("failed to load unconfirmed block: %w", err)
+	}
+	if priorBlockHash == "" || unconfirmed != "" {
+		if priorBlockHash == "" {
+			e.Log.Println("No prior block hash, must be first time executed")
+		} else {
+			// The proxy may have enqueued the block even though the post
+			// failed, in which case the next block must chain onto it.
+			e.Log.Printf("Block %s was posted without the NVL Proxy accepting it\n", unconfirmed)
+		}
+		if e.Config.RecoverState {
+			recovered, err := e.recoverPriorBlockHash(ctx, signingKey, guard)
+			if err != nil {
+				return result, fmt.Errorf("failed to recover prior block hash: %w", err)
+			}
+			if recovered != "" {
+				priorBlockHash = recovered
+			}
+		} else if unconfirmed != "" {
+			e.Log.Println("Continuing from the local prior block hash. Run \"independent-signer state recover\" if the NVL Proxy refuses the next block")
+		}
+	}
+
+	if attestation := guard.Signed(publicKey, proxyBlock.Seal.Proofs); attestation != nil {
+		result.AlreadySigned = attestation
+		return result, fmt.Errorf("%w in block %s, refusing to sign %s again", ErrAlreadySigned, attestation.Hash, proxyBlock.Seal.Proofs)
+	}
+
+	e.Log.Println("Loading previously attested NVL Proxy block")
+	priorProxyBlock, err := e.Store.LoadPriorProxyBlock()
+	if err != nil {
+		return result, fmt.Errorf("failed to load previously attested NVL Proxy block: %w", err)
+	}
+
+	err = CheckBlockTimestamp(proxyBlock, priorProxyBlock, time.Now(), e.Config.MaxFutureSkew, e.Config.MaxBlockAge)
+	if err != nil {
+		return result, fmt.Errorf("refusing to sign NVL Proxy block: %w", err)
+	}
+
+	if e.Config.CheckSupply {
+		if err := CheckSupply(proxyBlock, priorProxyBlock, e.Config.MaxSupplyIncreasePerHour); err != nil {
+			e.raiseAlert(ctx, fmt.Sprintf("NVL Proxy block %s: %s", proxyBlock.Seal.Proofs, err))
+			return result, fmt.Errorf("refusing to sign NVL Proxy block: %w", err)
+		}
+	}
+
+	if e.Config.VerifyChildren {
+		if err := e.verifyChildren(ctx, proxyBlock); err != nil {
+			return result, fmt.Errorf("refusing to sign NVL Proxy block: %w", err)
+		}
+		e.Log.Println("Every child block passed verification")
+	}
+
+	e.Log.Println("Creating independent NVL block")
+	block := NewIndependentBlock(signingKey, proxyBlock, priorBlockHash, time.Now())
+
+	hash, sig, err := SignBlock(signingKey, block)
+	if err != nil {
+		return result, fmt.Errorf("failed to sign independent block: %w", err)
+	}
+	block.Seal.Proofs = hash
+	block.Seal.Signature = sig
+	e.Log.Println("New independent NVL block signed!")
+	e.Log.Printf("Hash: %s\n", hash)
+	e.Log.Printf("Signature: %s\n", sig)
+
+	guard.Record(publicKey, block)
+	if err := e.Store.SaveSigningGuard(guard); err != nil {
+		return result, fmt.Errorf("failed to save signing guard: %w", err)
+	}
+	if err := e.Store.SaveAttestation(NewAttestation(block, proxyBlock, verifyingKey)); err != nil {
+		return result, fmt.Errorf("failed to save attestation: %w", err)
+	}
+
+	e.Log.Println("Posting new block to NVL Proxy")
+	statusCode, respBody, err := e.Client.PostIndependentBlock(ctx, block, e.Config.SignerVersion)
+	result.StatusCode = statusCode
+	if statusCode != 0 {
+		e.Log.Printf("NVL Proxy resp code: %d\n", statusCode)
+	}
+	if respBody != "" {
+		e.Log.Println(respBody)
+	}
+	if err != nil {
+		// The prior block hash only advances once the proxy accepted the
+		// block, so the chain does not continue from a block it never saw.
+		if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+			e.Log.Println("The NVL Proxy refused the block. Check that the Public Key is registered with: independent-signer registration status")
+		}
+		// The proxy block is in the signing guard and is not signed again,
+		// so the next run recovers the chain from the proxy instead.
+		if saveErr := e.Store.SaveUnconfirmedBlock(hash); saveErr != nil {
+			e.Log.Printf("failed to save unconfirmed block: %s", saveErr)
+		}
+		return result, fmt.Errorf("failed to post independent block to NVL proxy: %w", err)
+	}
+	result.IndependentBlock = block
+
+	e.Log.Printf("Saving prior block hash: %s\n", hash)
+	if err := e.Store.SavePriorBlockHash(hash); err != nil {
+		return result, fmt.Errorf("failed to save prior block hash: %w", err)
+	}
+
+	e.Log.Printf("Saving attested NVL Proxy block: %s\n", proxyBlock.Seal.Proofs)
+	if err := e.Store.SavePriorProxyBlock(proxyBlock, priorProxyBlock); err != nil {
+		return result, fmt.Errorf("failed to save attested NVL Proxy block: %w", err)
+	}
+
+	return result, nil
+}
+
+// fetchHead returns the proxy verifying key and the hash of the latest proxy
+// block, which is empty if the proxy has no blocks. With a consistency quorum
+// they must be the same on that many endpoints.
+func (e *Engine) fetchHead(ctx context.Context) ([]byte, string, error) {
+	if e.Config.ConsistencyQuorum > 1 {
+		e.Log.Printf("Fetching NVL Proxy verifying key and latest block from %d endpoints\n", e.Config.ConsistencyQuorum)
+		head, err := e.Client.FetchConsistentHead(ctx, e.Config.ConsistencyQuorum)
+		if errors.Is(err, ErrEndpointsDisagree) {
+			e.raiseAlert(ctx, err.Error())
+			return nil, "", fmt.Errorf("refusing to sign NVL Proxy block: %w", err)
+		} else if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch a consistent NVL Proxy view: %w", err)
+		}
+		return head.VerifyingKey, head.LatestBlockHash, nil
+	}
+
+	e.Log.Println("Loading NVL Proxy verifying key")
+	verifyingKey, err := e.Client.FetchVerifyingKey(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load verifying key: %w", err)
+	}
+
+	e.Log.Println("Fetching latest NVL Proxy block")
+	proxyBlockHash, err := e.Client.FetchLatestBlockHash(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch NVL block: %w", err)
+	}
+	return verifyingKey, proxyBlockHash, nil
+}
+
+// fetchProxyBlock returns the proxy block with hash from the block cache, or
+// fetches it from the NVL Proxy if no verified copy is cached. The block must
+// be verified again either way. cached reports whether it came from the cache.
+func (e *Engine) fetchProxyBlock(ctx context.Context, hash string) (block *NVLBlock, cached bool, err error) {
+	cachedBlock, err := e.Store.LoadCachedBlock(hash)
+	if err != nil {
+		e.Log.Printf("ignoring cached NVL Proxy block: %s", err)
+	} else if cachedBlock != nil && cachedBlock.Verified {
+		block, err := cachedBlock.Block()
+		if err == nil && block.Seal.Proofs == hash {
+			e.Log.Println("Using cached NVL Proxy block")
+			return block, true, nil
+		}
+		e.Log.Printf("ignoring cached NVL Proxy block %s, it does not match its hash", hash)
+	}
+	block, err = e.Client.FetchBlock(ctx, hash)
+	return block, false, err
+}
+
+// verifyProxyBlock checks that proxyBlock is in a known format and signed by
+// verifyingKey.
+func (e *Engine) verifyProxyBlock(verifyingKey []byte, proxyBlock *NVLBlock) error {
+	unknownFields, err := CheckBlockFormat(proxyBlock)
+	if err != nil {
+		return fmt.Errorf("cannot verify NVL block: %w", err)
+	}
+	if len(unknownFields) > 0 {
+		e.Log.Printf("NVL Proxy block has fields this signer does not know, verifying them as signed: %s\n", strings.Join(unknownFields, ", "))
+	}
+	if valid, err := VerifyBlock(verifyingKey, proxyBlock); err != nil {
+		return fmt.Errorf("error verifying NVL block: %w", err)
+	} else if !valid {
+		return ErrInvalidProxyBlock
+	}
+	return nil
+}
+
+// loadSigningKey loads the signing key, generating one the first time the
+// signer runs.
+func (e *Engine) loadSigningKey() (*ecdsa.PrivateKey, error) {
+	e.Log.Println("Loading signing key")
+	exists, err := e.Store.HasSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKey *ecdsa.PrivateKey
+	if exists {
+		signingKey, err = e.Store.LoadSigningKey()
+	} else {
+		e.Log.Println("Signing key not found")
+		e.Log.Println("Generating signing key")
+		signingKey, err = e.Store.GenerateSigningKey()
+		if err == nil {
+			e.Log.Println("New signing key generated")
+			if err := e.Store.SavePublicKey(signingKey); err != nil {
+				e.Log.Printf("failed to save public key: %s", err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.Log.Printf("Public Key: %s\n", PublicKeyHex(signingKey))
+	return signingKey, nil
+}
+
+func (e *Engine) checkClockSkew() error {
+	offset, ok := e.Clock.Offset()
+	if !ok {
+		e.Log.Println("Could not estimate clock skew against the NVL Proxy")
+		return nil
+	}
+
+	skew := offset
+	if skew < 0 {
+		skew = -skew
+	}
+	e.Log.Printf("Estimated clock offset against NVL Proxy: %s\n", offset.Round(time.Millisecond))
+
+	if e.Config.MaxClockSkew > 0 && skew > e.Config.MaxClockSkew {
+		return fmt.Errorf("%w: offset %s exceeds %s", ErrClockSkew, offset.Round(time.Second), e.Config.MaxClockSkew)
+	}
+	if e.Config.WarnClockSkew > 0 && skew > e.Config.WarnClockSkew {
+		e.Log.Printf("WARNING: local clock is %s away from the NVL Proxy clock, please check the system time\n", skew.Round(time.Second))
+	}
+
+	return nil
+}
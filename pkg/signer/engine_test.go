@@ -0,0 +1,255 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/nvlmock"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// newMockProxy starts a mock NVL Proxy holding one proxy block, serving it
+// through wrap if it is not nil.
+func newMockProxy(t *testing.T, wrap func(http.Handler) http.Handler) (*nvlmock.Server, *httptest.Server) {
+	t.Helper()
+	mock, err := nvlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mock.AddBlock("1000000"); err != nil {
+		t.Fatal(err)
+	}
+	var handler http.Handler = mock
+	if wrap != nil {
+		handler = wrap(mock)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return mock, server
+}
+
+func newTestEngine(t *testing.T, baseURLs ...string) *signer.Engine {
+	t.Helper()
+	store := signer.NewStore(t.TempDir())
+	if _, err := store.GenerateSigningKey(); err != nil {
+		t.Fatal(err)
+	}
+	config := signer.DefaultConfig()
+	config.BaseURLs = baseURLs
+	engine, err := signer.NewEngine(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Log = log.New(io.Discard, "", 0)
+	return engine
+}
+
+func priorBlockHash(t *testing.T, engine *signer.Engine) string {
+	t.Helper()
+	hash, err := engine.Store.LoadPriorBlockHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestRunOnce(t *testing.T) {
+	mock, server := newMockProxy(t, nil)
+	engine := newTestEngine(t, server.URL)
+
+	result, err := engine.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IndependentBlock == nil || result.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	enqueued := mock.Enqueued()
+	if len(enqueued) != 1 || enqueued[0].Seal.Proofs != result.IndependentBlock.Seal.Proofs {
+		t.Fatalf("enqueued %d blocks", len(enqueued))
+	}
+	first := enqueued[0].Seal.Proofs
+	if enqueued[0].Blocks[0] != result.ProxyBlock.Seal.Proofs {
+		t.Errorf("signed %s, want the proxy block %s", enqueued[0].Blocks[0], result.ProxyBlock.Seal.Proofs)
+	}
+	if hash := priorBlockHash(t, engine); hash != first {
+		t.Errorf("prior block hash is %s, want %s", hash, first)
+	}
+
+	// The next proxy block is chained onto the first independent block. Its
+	// timestamp, in whole seconds, must be later than the first one's.
+	mock.SetFaults(nvlmock.Faults{ClockOffset: 2 * time.Second})
+	if _, err := mock.AddBlock("1000001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	enqueued = mock.Enqueued()
+	if len(enqueued) != 2 || enqueued[1].Header.PriorBlock != first {
+		t.Errorf("second block does not follow %s", first)
+	}
+
+	// Without a new proxy block, signing is refused.
+	result, err = engine.RunOnce(context.Background())
+	if !errors.Is(err, signer.ErrAlreadySigned) || result.AlreadySigned == nil || result.AlreadySigned.Hash != enqueued[1].Seal.Proofs {
+		t.Errorf("got %+v, %v, want ErrAlreadySigned", result, err)
+	}
+	if len(mock.Enqueued()) != 2 {
+		t.Error("the proxy block was signed again")
+	}
+	status, err := engine.Store.LoadStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run := status.LastRunRecord(); run == nil || run.Outcome != signer.RunAlreadySigned || run.Error == "" {
+		t.Errorf("last run recorded as %+v", run)
+	}
+}
+
+func TestRunOnceBadProxySignature(t *testing.T) {
+	mock, server := newMockProxy(t, nil)
+	mock.SetFaults(nvlmock.Faults{BadSignature: true})
+	engine := newTestEngine(t, server.URL)
+
+	if _, err := engine.RunOnce(context.Background()); err == nil {
+		t.Fatal("signed a proxy block with a bad signature")
+	}
+	if len(mock.Enqueued()) != 0 {
+		t.Error("a block was enqueued")
+	}
+	if hash := priorBlockHash(t, engine); hash != "" {
+		t.Errorf("prior block hash advanced to %s", hash)
+	}
+}
+
+func TestRunOnceFailsOver(t *testing.T) {
+	primary, primaryServer := newMockProxy(t, nil)
+	primary.SetFaults(nvlmock.Faults{ServerError: true})
+	secondary, secondaryServer := newMockProxy(t, nil)
+	engine := newTestEngine(t, primaryServer.URL, secondaryServer.URL)
+
+	result, err := engine.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.Enqueued()) != 0 || len(secondary.Enqueued()) != 1 {
+		t.Errorf("enqueued %d blocks on the primary and %d on the secondary", len(primary.Enqueued()), len(secondary.Enqueued()))
+	}
+	if hash := priorBlockHash(t, engine); hash != result.IndependentBlock.Seal.Proofs {
+		t.Errorf("prior block hash is %s, want %s", hash, result.IndependentBlock.Seal.Proofs)
+	}
+	for _, endpoint := range engine.Client.Health() {
+		if healthy := endpoint.URL == secondaryServer.URL; endpoint.Healthy != healthy {
+			t.Errorf("endpoint %s healthy: %t", endpoint.URL, endpoint.Healthy)
+		}
+	}
+}
+
+func TestRunOnceRefusedEnqueue(t *testing.T) {
+	failEnqueue := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				http.Error(w, "injected server error", http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	primary, primaryServer := newMockProxy(t, failEnqueue)
+	secondary, secondaryServer := newMockProxy(t, nil)
+	engine := newTestEngine(t, primaryServer.URL, secondaryServer.URL)
+
+	if _, err := engine.RunOnce(context.Background()); err == nil {
+		t.Fatal("a refused block was reported as posted")
+	}
+	// The primary may have enqueued the block, so it is not sent again.
+	if len(primary.Enqueued()) != 0 || len(secondary.Enqueued()) != 0 {
+		t.Error("the block was enqueued")
+	}
+	if hash := priorBlockHash(t, engine); hash != "" {
+		t.Errorf("prior block hash advanced to %s", hash)
+	}
+}
+
+func TestRunOnceRecoversAfterUnconfirmedPost(t *testing.T) {
+	// The proxy enqueues the second block but its response is lost.
+	var posts int
+	loseResponse := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				if posts++; posts == 2 {
+					next.ServeHTTP(httptest.NewRecorder(), r)
+					http.Error(w, "injected gateway timeout", http.StatusGatewayTimeout)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	mock, server := newMockProxy(t, loseResponse)
+	engine := newTestEngine(t, server.URL)
+
+	if _, err := engine.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := priorBlockHash(t, engine)
+
+	mock.SetFaults(nvlmock.Faults{ClockOffset: 2 * time.Second})
+	if _, err := mock.AddBlock("1000001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.RunOnce(context.Background()); err == nil {
+		t.Fatal("a block without a response was reported as posted")
+	}
+	enqueued := mock.Enqueued()
+	if len(enqueued) != 2 {
+		t.Fatalf("enqueued %d blocks, want 2", len(enqueued))
+	}
+	second := enqueued[1].Seal.Proofs
+	if hash := priorBlockHash(t, engine); hash != first {
+		t.Errorf("prior block hash is %s, want %s", hash, first)
+	}
+	if unconfirmed, err := engine.Store.LoadUnconfirmedBlock(); err != nil || unconfirmed != second {
+		t.Errorf("unconfirmed block is %q, %v, want %s", unconfirmed, err, second)
+	}
+
+	// The next block chains onto the one the proxy did receive.
+	mock.SetFaults(nvlmock.Faults{ClockOffset: 4 * time.Second})
+	if _, err := mock.AddBlock("1000002"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	enqueued = mock.Enqueued()
+	if len(enqueued) != 3 || enqueued[2].Header.PriorBlock != second {
+		t.Errorf("third block does not follow %s", second)
+	}
+	if unconfirmed, err := engine.Store.LoadUnconfirmedBlock(); err != nil || unconfirmed != "" {
+		t.Errorf("unconfirmed block is %q, %v, want none", unconfirmed, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CurrentBlockVersion is the block format version independent blocks are
+// created with.
+const CurrentBlockVersion = "1"
+
+var (
+	// ErrUnsupportedBlockVersion is returned for blocks whose format version
+	// this signer has no encoder for.
+	ErrUnsupportedBlockVersion = errors.New("unsupported block version")
+	// ErrUnknownBlockFields is returned for blocks carrying top level fields
+	// their format version does not define, which cannot be placed in the
+	// signing payload.
+	ErrUnknownBlockFields = errors.New("block has fields this signer does not know")
+)
+
+// BlockFormat describes one version of the block format: the fields it
+// defines and how its signing payload is encoded.
+type BlockFormat struct {
+	Version string
+	// Fields are the top level block fields.
+	Fields []string
+	// HeaderFields are the header fields.
+	HeaderFields []string
+	// MarshalForSigning returns the canonical signing payload of a block.
+	MarshalForSigning func(b *NVLBlock) ([]byte, error)
+	// CanonicalizeRaw returns the canonical signing payload of a block from
+	// its raw JSON, keeping header fields the format does not define.
+	CanonicalizeRaw func(raw []byte) ([]byte, error)
+}
+
+var blockFormats = map[string]*BlockFormat{}
+
+// RegisterBlockFormat adds a block format version. It panics if the version is
+// already registered.
+func RegisterBlockFormat(format *BlockFormat) {
+	if _, ok := blockFormats[format.Version]; ok {
+		panic(fmt.Sprintf("block format version %q registered twice", format.Version))
+	}
+	blockFormats[format.Version] = format
+}
+
+// LookupBlockFormat returns the format for a block version.
+func LookupBlockFormat(version string) (*BlockFormat, error) {
+	format, ok := blockFormats[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, please upgrade the independent signer", ErrUnsupportedBlockVersion, version)
+	}
+	return format, nil
+}
+
+func init() {
+	RegisterBlockFormat(&BlockFormat{
+		Version:           "1",
+		Fields:            []string{"version", "header", "blocks", "signature"},
+		HeaderFields:      []string{"type", "priorBlock", "timestamp", "publicKey", "coiinSupply"},
+		MarshalForSigning: marshalForSigningV1,
+		CanonicalizeRaw:   canonicalizeRawV1,
+	})
+}
+
+// marshalForSigningV1 encodes version 1 blocks, see docs/signing-payload.md.
+func marshalForSigningV1(b *NVLBlock) ([]byte, error) {
+	blocks := b.Blocks
+	if blocks == nil {
+		blocks = make([]string, 0)
+	}
+
+	data := map[string]interface{}{
+		"header": map[string]string{
+			"type":       b.Header.Type,
+			"priorBlock": b.Header.PriorBlock,
+			"timestamp":  b.Header.Timestamp,
+			"publicKey":  b.Header.PublicKey,
+		},
+		"blocks":  blocks,
+		"version": b.Version,
+	}
+
+	if b.Header.CoiinSupply != "" {
+		data["header"].(map[string]string)["coiinSupply"] = b.Header.CoiinSupply
+	}
+
+	return json.Marshal(data)
+}
+
+// canonicalizeRawV1 encodes the raw JSON of a version 1 block the same way as
+// marshalForSigningV1, except that unknown header fields are kept.
+func canonicalizeRawV1(raw []byte) ([]byte, error) {
+	block := &struct {
+		Version string                     `json:"version"`
+		Header  map[string]json.RawMessage `json:"header"`
+		Blocks  []string                   `json:"blocks"`
+	}{}
+	if err := json.Unmarshal(raw, block); err != nil {
+		return nil, err
+	}
+	if block.Header == nil {
+		return nil, errors.New("block has no header")
+	}
+	if block.Blocks == nil {
+		block.Blocks = make([]string, 0)
+	}
+
+	header := make(map[string]interface{}, len(block.Header))
+	for field, value := range block.Header {
+		switch field {
+		case "type", "priorBlock", "timestamp", "publicKey", "coiinSupply":
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, fmt.Errorf("invalid header field %s: %w", field, err)
+			}
+			header[field] = s
+		default:
+			decoder := json.NewDecoder(bytes.NewReader(value))
+			decoder.UseNumber()
+			var v interface{}
+			if err := decoder.Decode(&v); err != nil {
+				return nil, fmt.Errorf("invalid header field %s: %w", field, err)
+			}
+			header[field] = v
+		}
+	}
+	for _, field := range []string{"type", "priorBlock", "timestamp", "publicKey"} {
+		if _, ok := header[field]; !ok {
+			header[field] = ""
+		}
+	}
+	if supply, ok := header["coiinSupply"]; ok && supply == "" {
+		delete(header, "coiinSupply")
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"header":  header,
+		"blocks":  block.Blocks,
+		"version": block.Version,
+	})
+}
+
+// CheckBlockFormat checks that the signer knows block's format version and,
+// for blocks parsed from the NVL Proxy, that the raw payload has no top level
+// fields the version does not define. Unknown header fields are included in the
+// signing payload, so they are returned rather than treated as an error.
+func CheckBlockFormat(block *NVLBlock) ([]string, error) {
+	format, err := LookupBlockFormat(block.Version)
+	if err != nil {
+		return nil, err
+	}
+	if block.raw == "" {
+		return nil, nil
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(block.raw), &raw); err != nil {
+		return nil, err
+	}
+	if unknown := unknownFields(raw, format.Fields, ""); len(unknown) > 0 {
+		return nil, fmt.Errorf("%w in version %q: %s, please upgrade the independent signer",
+			ErrUnknownBlockFields, block.Version, strings.Join(unknown, ", "))
+	}
+
+	header := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw["header"], &header); err != nil {
+		return nil, fmt.Errorf("invalid block header: %w", err)
+	}
+	return unknownFields(header, format.HeaderFields, "header."), nil
+}
+
+func unknownFields(fields map[string]json.RawMessage, known []string, prefix string) []string {
+	var unknown []string
+	for field := range fields {
+		found := false
+		for _, k := range known {
+			if field == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, prefix+field)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
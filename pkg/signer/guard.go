@@ -0,0 +1,254 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// SigningGuardVersion is the version of the signing guard interchange format.
+const SigningGuardVersion = "1"
+
+// MaxGuardAttestations is how many attestations are kept for each key. The
+// oldest are pruned first: the proxy blocks they cover are older than the
+// last attested one, so the timestamp checks refuse them anyway.
+const MaxGuardAttestations = 10000
+
+// GuardAttestation records a single independent block signed over a proxy
+// block.
+type GuardAttestation struct {
+	ProxyBlock string `json:"proxyBlock"`
+	PriorBlock string `json:"priorBlock"`
+	Hash       string `json:"hash"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// GuardKey is the record of the proxy blocks signed by one signing key,
+// identified by its hex encoded public key. Its Attestations are looked up by
+// proxy block hash.
+type GuardKey struct {
+	PublicKey    string              `json:"publicKey"`
+	Attestations []*GuardAttestation `json:"attestations"`
+}
+
+// SigningGuard is the record of every proxy block signed by each key. It is
+// consulted before signing so that a restored backup or a second copy of the
+// signer cannot attest the same proxy block twice and fork the independent
+// chain. The same format is used to export and import the guard.
+type SigningGuard struct {
+	Version string      `json:"version"`
+	Keys    []*GuardKey `json:"keys"`
+
+	// index maps public keys and proxy block hashes to the attestations in
+	// Keys. It is built on first use and kept up to date by Record and Merge.
+	index map[string]map[string]*GuardAttestation
+}
+
+// NewSigningGuard returns an empty guard.
+func NewSigningGuard() *SigningGuard {
+	return &SigningGuard{Version: SigningGuardVersion, Keys: []*GuardKey{}}
+}
+
+// ReadSigningGuard reads a guard or a guard export from path.
+func ReadSigningGuard(path string) (*SigningGuard, error) {
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	guard := new(SigningGuard)
+	if err := json.Unmarshal(fileData, guard); err != nil {
+		return nil, err
+	}
+	if guard.Version != SigningGuardVersion {
+		return nil, fmt.Errorf("unsupported signing guard version %q", guard.Version)
+	}
+	return guard, nil
+}
+
+// Write writes the guard to path in the interchange format.
+func (g *SigningGuard) Write(path string) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0600)
+}
+
+func (g *SigningGuard) key(publicKey string) *GuardKey {
+	for _, key := range g.Keys {
+		if key.PublicKey == publicKey {
+			return key
+		}
+	}
+	key := &GuardKey{PublicKey: publicKey}
+	g.Keys = append(g.Keys, key)
+	return key
+}
+
+func (g *SigningGuard) indexed(publicKey string) map[string]*GuardAttestation {
+	if g.index == nil {
+		g.index = make(map[string]map[string]*GuardAttestation, len(g.Keys))
+		for _, key := range g.Keys {
+			g.indexKey(key)
+		}
+	}
+	return g.index[publicKey]
+}
+
+func (g *SigningGuard) indexKey(key *GuardKey) {
+	attestations := make(map[string]*GuardAttestation, len(key.Attestations))
+	for _, attestation := range key.Attestations {
+		if _, ok := attestations[attestation.ProxyBlock]; !ok {
+			attestations[attestation.ProxyBlock] = attestation
+		}
+	}
+	g.index[key.PublicKey] = attestations
+}
+
+func (g *SigningGuard) add(key *GuardKey, attestation *GuardAttestation) {
+	key.Attestations = append(key.Attestations, attestation)
+	attestations := g.indexed(key.PublicKey)
+	if attestations == nil {
+		attestations = make(map[string]*GuardAttestation)
+		g.index[key.PublicKey] = attestations
+	}
+	if _, ok := attestations[attestation.ProxyBlock]; !ok {
+		attestations[attestation.ProxyBlock] = attestation
+	}
+}
+
+// prune drops the oldest attestations of key beyond MaxGuardAttestations.
+func (g *SigningGuard) prune(key *GuardKey) {
+	if len(key.Attestations) <= MaxGuardAttestations {
+		return
+	}
+	signedAt := func(attestation *GuardAttestation) time.Time {
+		timestamp, _ := ParseBlockTimestamp(attestation.Timestamp)
+		return timestamp
+	}
+	sort.SliceStable(key.Attestations, func(i, j int) bool {
+		return signedAt(key.Attestations[i]).Before(signedAt(key.Attestations[j]))
+	})
+	key.Attestations = key.Attestations[len(key.Attestations)-MaxGuardAttestations:]
+	if g.index != nil {
+		g.indexKey(key)
+	}
+}
+
+// Signed returns the attestation made by publicKey over proxyBlock, if any.
+func (g *SigningGuard) Signed(publicKey, proxyBlock string) *GuardAttestation {
+	return g.indexed(publicKey)[proxyBlock]
+}
+
+// Record adds the proxy blocks attested by the signed independent block.
+func (g *SigningGuard) Record(publicKey string, block *NVLBlock) {
+	key := g.key(publicKey)
+	for _, proxyBlock := range block.Blocks {
+		g.add(key, &GuardAttestation{
+			ProxyBlock: proxyBlock,
+			PriorBlock: block.Header.PriorBlock,
+			Hash:       block.Seal.Proofs,
+			Timestamp:  block.Header.Timestamp,
+		})
+	}
+	g.prune(key)
+}
+
+// GuardConflict is a proxy block that the same key signed into two different
+// independent blocks.
+type GuardConflict struct {
+	PublicKey  string
+	ProxyBlock string
+	Hashes     [2]string
+}
+
+// Merge adds every attestation from other that is not already recorded. It
+// returns how many were added and any proxy blocks that were signed twice.
+func (g *SigningGuard) Merge(other *SigningGuard) (int, []GuardConflict) {
+	added := 0
+	var conflicts []GuardConflict
+	for _, otherKey := range other.Keys {
+		key := g.key(otherKey.PublicKey)
+		for _, attestation := range otherKey.Attestations {
+			if existing := g.Signed(otherKey.PublicKey, attestation.ProxyBlock); existing != nil {
+				if existing.Hash != attestation.Hash {
+					conflicts = append(conflicts, GuardConflict{
+						PublicKey:  otherKey.PublicKey,
+						ProxyBlock: attestation.ProxyBlock,
+						Hashes:     [2]string{existing.Hash, attestation.Hash},
+					})
+				}
+				continue
+			}
+			g.add(key, attestation)
+			added++
+		}
+		g.prune(key)
+	}
+	return added, conflicts
+}
+
+// ExportGuard writes the signing guard to path so it can be moved together
+// with the signing key.
+func (e *Engine) ExportGuard(path string) error {
+	lock, err := e.Store.Lock()
+	if err != nil {
+		return fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	guard, err := e.Store.LoadSigningGuard()
+	if err != nil {
+		return err
+	}
+	return guard.Write(path)
+}
+
+// ImportGuard merges a signing guard export into the local guard and returns
+// how many attestations were added.
+func (e *Engine) ImportGuard(path string) (int, error) {
+	lock, err := e.Store.Lock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	imported, err := ReadSigningGuard(path)
+	if err != nil {
+		return 0, err
+	}
+	guard, err := e.Store.LoadSigningGuard()
+	if err != nil {
+		return 0, err
+	}
+
+	added, conflicts := guard.Merge(imported)
+	for _, conflict := range conflicts {
+		e.Log.Printf("Proxy block %s was signed twice by %s: %s and %s\n",
+			conflict.ProxyBlock, conflict.PublicKey, conflict.Hashes[0], conflict.Hashes[1])
+	}
+	if err := e.Store.SaveSigningGuard(guard); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func guardBlock(proxyBlock string, n int) *NVLBlock {
+	return &NVLBlock{
+		Header: &NVLBlockHeader{Timestamp: strconv.Itoa(1700000000 + n)},
+		Blocks: []string{proxyBlock},
+		Seal:   &NVLBlockSeal{Proofs: fmt.Sprintf("independent-%d", n)},
+	}
+}
+
+func TestSigningGuard(t *testing.T) {
+	guard := NewSigningGuard()
+	guard.Record("key", guardBlock("proxy-1", 1))
+	if attestation := guard.Signed("key", "proxy-1"); attestation == nil || attestation.Hash != "independent-1" {
+		t.Errorf("proxy-1 signed in %+v", attestation)
+	}
+	if guard.Signed("other key", "proxy-1") != nil || guard.Signed("key", "proxy-2") != nil {
+		t.Error("unsigned blocks reported as signed")
+	}
+
+	// A guard read back from its file is indexed too.
+	path := t.TempDir() + "/guard.json"
+	if err := guard.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSigningGuard(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Signed("key", "proxy-1") == nil {
+		t.Error("proxy-1 not signed after reading the guard")
+	}
+
+	other := NewSigningGuard()
+	other.Record("key", guardBlock("proxy-1", 5))
+	other.Record("key", guardBlock("proxy-2", 2))
+	added, conflicts := read.Merge(other)
+	if added != 1 || len(conflicts) != 1 || conflicts[0].Hashes != [2]string{"independent-1", "independent-5"} {
+		t.Errorf("merge added %d with conflicts %+v", added, conflicts)
+	}
+	if read.Signed("key", "proxy-2") == nil {
+		t.Error("merged attestation not found")
+	}
+}
+
+func TestSigningGuardPrunes(t *testing.T) {
+	guard := NewSigningGuard()
+	for n := 0; n < MaxGuardAttestations+10; n++ {
+		guard.Record("key", guardBlock(fmt.Sprintf("proxy-%d", n), n))
+	}
+	if count := len(guard.Keys[0].Attestations); count != MaxGuardAttestations {
+		t.Errorf("guard keeps %d attestations, want %d", count, MaxGuardAttestations)
+	}
+	if guard.Signed("key", "proxy-0") != nil || guard.Signed("key", "proxy-9") != nil {
+		t.Error("the oldest attestations were not pruned")
+	}
+	if guard.Signed("key", "proxy-10") == nil || guard.Signed("key", fmt.Sprintf("proxy-%d", MaxGuardAttestations+9)) == nil {
+		t.Error("recent attestations were pruned")
+	}
+}
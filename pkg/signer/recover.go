@@ -0,0 +1,100 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+)
+
+// Recover resumes the independent chain from the most recent independent
+// block the NVL Proxy holds for the signing key, replacing the local prior
+// block hash. It returns the recovered hash, or an empty string if the proxy
+// holds no blocks for the key.
+func (e *Engine) Recover(ctx context.Context) (string, error) {
+	lock, err := e.Store.Lock()
+	if err != nil {
+		return "", fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	if err := e.Store.Check(); err != nil {
+		return "", fmt.Errorf("data directory check failed: %w", err)
+	}
+
+	signingKey, err := e.loadSigningKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %w", err)
+	}
+	guard, err := e.Store.LoadSigningGuard()
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing guard: %w", err)
+	}
+	previous, err := e.Store.LoadPriorBlockHash()
+	if err != nil {
+		return "", fmt.Errorf("failed to load prior block hash: %w", err)
+	}
+
+	recovered, err := e.recoverPriorBlockHash(ctx, signingKey, guard)
+	if err != nil {
+		return "", fmt.Errorf("failed to recover prior block hash: %w", err)
+	}
+	if recovered != "" && previous != "" && previous != recovered {
+		e.Log.Printf("Replaced local prior block hash %s\n", previous)
+	}
+	return recovered, nil
+}
+
+// recoverPriorBlockHash asks the NVL Proxy for the most recent independent
+// block it holds for signingKey and resumes the local chain from it, after
+// checking that the block was signed by signingKey. It returns an empty hash if
+// the proxy has no blocks for the key.
+func (e *Engine) recoverPriorBlockHash(ctx context.Context, signingKey *ecdsa.PrivateKey, guard *SigningGuard) (string, error) {
+	e.Log.Println("Recovering prior block hash from NVL Proxy")
+
+	publicKey := PublicKeyHex(signingKey)
+	blockHash, err := e.Client.FetchLatestIndependentBlockHash(ctx, publicKey)
+	if err != nil {
+		return "", err
+	} else if blockHash == "" {
+		e.Log.Println("NVL Proxy holds no independent blocks for this key")
+		return "", nil
+	}
+
+	block, err := e.Client.FetchBlock(ctx, blockHash)
+	if err != nil {
+		return "", err
+	}
+	if err := VerifyOwnIndependentBlock(signingKey, blockHash, block); err != nil {
+		return "", fmt.Errorf("refusing to resume from block %s: %w", blockHash, err)
+	}
+
+	if guard.Signed(publicKey, block.Blocks[0]) == nil {
+		guard.Record(publicKey, block)
+		if err := e.Store.SaveSigningGuard(guard); err != nil {
+			return "", err
+		}
+	}
+	if err := e.Store.SavePriorBlockHash(blockHash); err != nil {
+		return "", err
+	}
+
+	e.Log.Printf("Resuming independent chain from block %s\n", blockHash)
+	return blockHash, nil
+}
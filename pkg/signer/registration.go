@@ -0,0 +1,259 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Registration statuses, as reported by the registration endpoint.
+const (
+	// RegistrationRegistered means the public key is linked to a Coiin
+	// Console account and its blocks are accepted.
+	RegistrationRegistered = "registered"
+	// RegistrationPending means ownership of the key was proven, but the key
+	// still has to be linked to an account at the ClaimURL.
+	RegistrationPending = "pending"
+	// RegistrationUnregistered means the endpoint does not know the key.
+	RegistrationUnregistered = "unregistered"
+)
+
+// registrationDomain starts every signed registration message, so that a
+// registration signature can never be passed off as a block seal.
+const registrationDomain = "Coiin NVL node registration\n"
+
+// RegistrationChallenge is a one-time value issued by the registration
+// endpoint for the signer to sign.
+type RegistrationChallenge struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RegistrationRequest proves ownership of PublicKey by signing either a
+// Challenge issued by the endpoint or a Token generated in the Coiin Console.
+type RegistrationRequest struct {
+	Version                  string `json:"version"`
+	PublicKey                string `json:"publicKey"`
+	Challenge                string `json:"challenge,omitempty"`
+	Token                    string `json:"token,omitempty"`
+	Signature                string `json:"signature"`
+	IndependentSignerVersion string `json:"independentSignerVersion"`
+}
+
+// Registration is the registration state of a public key.
+type Registration struct {
+	PublicKey string `json:"publicKey"`
+	Status    string `json:"status"`
+	// ClaimURL is where a pending registration is linked to a Coiin Console
+	// account.
+	ClaimURL     string     `json:"claimURL,omitempty"`
+	RegisteredAt *time.Time `json:"registeredAt,omitempty"`
+	// Message is an explanation from the endpoint, if any.
+	Message string `json:"message,omitempty"`
+}
+
+// RegistrationError is returned when the registration endpoint rejects a
+// request.
+type RegistrationError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RegistrationError) Error() string {
+	return fmt.Sprintf("registration endpoint returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap makes a rejected request count as an answer, not as an endpoint
+// failure.
+func (e *RegistrationError) Unwrap() error {
+	return &StatusError{StatusCode: e.StatusCode}
+}
+
+// RegistrationHash returns the hash signed to prove ownership of publicKey:
+// the Keccak-256 hash of registrationDomain, the hex encoded public key, a
+// newline and the challenge or token.
+func RegistrationHash(publicKey, challenge string) []byte {
+	return crypto.Keccak256([]byte(registrationDomain + publicKey + "\n" + challenge))
+}
+
+// SignRegistration signs challenge, a registration challenge or token, with
+// signingKey and returns the hex encoded 65 byte signature.
+func SignRegistration(signingKey *ecdsa.PrivateKey, challenge string) (string, error) {
+	signature, err := crypto.Sign(RegistrationHash(PublicKeyHex(signingKey), challenge), signingKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0130x", signature), nil
+}
+
+// VerifyRegistration checks that signature is a registration signature over
+// challenge made by the hex encoded publicKey.
+func VerifyRegistration(publicKey, challenge, signature string) (bool, error) {
+	key, err := hexutil.Decode("0x" + publicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key: %w", err)
+	}
+	sig, err := hexutil.Decode("0x" + signature)
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != 65 {
+		return false, fmt.Errorf("signature is %d bytes, expected 65", len(sig))
+	}
+	return crypto.VerifySignature(key, RegistrationHash(publicKey, challenge), sig[:64]), nil
+}
+
+// FetchRegistrationChallenge asks the registration endpoint for a challenge
+// to sign for publicKey.
+func (c *Client) FetchRegistrationChallenge(ctx context.Context, publicKey string) (*RegistrationChallenge, error) {
+	challenge := &RegistrationChallenge{}
+	body := map[string]string{"publicKey": publicKey}
+	if err := c.postJSON(ctx, "/api/v1/registration/challenge", body, challenge); err != nil {
+		return nil, err
+	}
+	if challenge.Challenge == "" {
+		return nil, errors.New("the registration endpoint returned an empty challenge")
+	}
+	return challenge, nil
+}
+
+// SubmitRegistration submits a signed registration request.
+func (c *Client) SubmitRegistration(ctx context.Context, request *RegistrationRequest) (*Registration, error) {
+	registration := &Registration{}
+	if err := c.postJSON(ctx, "/api/v1/registration", request, registration); err != nil {
+		return nil, err
+	}
+	return registration, nil
+}
+
+// FetchRegistration returns the registration state of publicKey. A key the
+// endpoint does not know is reported as RegistrationUnregistered.
+func (c *Client) FetchRegistration(ctx context.Context, publicKey string) (*Registration, error) {
+	body, err := c.get(ctx, "/api/v1/registration/"+url.PathEscape(publicKey))
+	if isNotFound(err) {
+		return &Registration{PublicKey: publicKey, Status: RegistrationUnregistered}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	registration := &Registration{}
+	if err := json.Unmarshal(body, registration); err != nil {
+		return nil, fmt.Errorf("invalid registration: %w", err)
+	}
+	return registration, nil
+}
+
+// postJSON posts body as JSON to path and decodes the response into out.
+// Rejected requests are returned as a *RegistrationError.
+func (c *Client) postJSON(ctx context.Context, path string, body, out interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.failover(ctx, func(baseURL string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(jsonBody))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 500 {
+			return &StatusError{StatusCode: resp.StatusCode}
+		}
+		if resp.StatusCode > 299 {
+			return &RegistrationError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(respBody))}
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("invalid response from %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// Register proves ownership of the signing key to the registration endpoint
+// and submits it for registration. With a token generated in the Coiin
+// Console the token is signed, which links the key to the account that
+// generated it. Without one, a challenge is fetched from the endpoint and
+// signed, and the returned registration is usually pending until it is
+// claimed in the Console.
+func (e *Engine) Register(ctx context.Context, token string) (*Registration, error) {
+	signingKey, err := e.Store.LoadSigningKey()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("there is no signing key yet, run the signer or install it first")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	publicKey := PublicKeyHex(signingKey)
+
+	request := &RegistrationRequest{
+		Version:                  "1",
+		PublicKey:                publicKey,
+		Token:                    token,
+		IndependentSignerVersion: e.Config.SignerVersion,
+	}
+	signed := token
+	if token == "" {
+		challenge, err := e.Registry.FetchRegistrationChallenge(ctx, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch a registration challenge: %w", err)
+		}
+		request.Challenge, signed = challenge.Challenge, challenge.Challenge
+	}
+	if request.Signature, err = SignRegistration(signingKey, signed); err != nil {
+		return nil, err
+	}
+
+	registration, err := e.Registry.SubmitRegistration(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register: %w", err)
+	}
+	return registration, nil
+}
+
+// RegistrationStatus returns the registration state of the signing key.
+func (e *Engine) RegistrationStatus(ctx context.Context) (*Registration, error) {
+	signingKey, err := e.Store.LoadSigningKey()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("there is no signing key yet, run the signer or install it first")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return e.Registry.FetchRegistration(ctx, PublicKeyHex(signingKey))
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+func TestSignRegistration(t *testing.T) {
+	signingKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := signer.PublicKeyHex(signingKey)
+
+	signature, err := signer.SignRegistration(signingKey, "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                            string
+		publicKey, challenge, signature string
+		valid                           bool
+	}{
+		{"round trip", publicKey, "challenge", signature, true},
+		{"other challenge", publicKey, "another challenge", signature, false},
+		{"other key", signer.PublicKeyHex(otherKey), "challenge", signature, false},
+	}
+	for _, test := range tests {
+		valid, err := signer.VerifyRegistration(test.publicKey, test.challenge, test.signature)
+		if err != nil || valid != test.valid {
+			t.Errorf("%s: verified %t, %v, want %t", test.name, valid, err, test.valid)
+		}
+	}
+	if _, err := signer.VerifyRegistration(publicKey, "challenge", signature[:128]); err == nil {
+		t.Error("a truncated signature was accepted")
+	}
+}
+
+func TestRegistrationIsNotABlockSeal(t *testing.T) {
+	signingKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyBlock := &signer.NVLBlock{
+		Header: &signer.NVLBlockHeader{CoiinSupply: "1000000"},
+		Seal:   &signer.NVLBlockSeal{Proofs: "13d57ac14552d6eb8fe200fcc07592d9abb85dae21d7716d575c5e045569b9b5"},
+	}
+	block := signer.NewIndependentBlock(signingKey, proxyBlock, "", time.Unix(1700000000, 0))
+	hash, sig, err := signer.SignBlock(signingKey, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := signer.PublicKeyHex(signingKey)
+
+	// A registration over the block hash, in either form, must not seal the
+	// block.
+	hashBytes, err := block.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, challenge := range []string{hash, string(hashBytes)} {
+		registration, err := signer.SignRegistration(signingKey, challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Seal.Signature = registration
+		if valid, err := signer.VerifyBlock(crypto.FromECDSAPub(&signingKey.PublicKey), block); err != nil || valid {
+			t.Errorf("a registration signature verified as a block seal: %t, %v", valid, err)
+		}
+	}
+
+	// Nor can a block seal be passed off as a registration.
+	if valid, err := signer.VerifyRegistration(publicKey, hash, sig); err != nil || valid {
+		t.Errorf("a block seal verified as a registration: %t, %v", valid, err)
+	}
+}
+
+// recordRegistrations captures the registration requests sent to next.
+type recordRegistrations struct {
+	mu         sync.Mutex
+	challenges int
+	requests   []*signer.RegistrationRequest
+}
+
+func (r *recordRegistrations) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost && req.URL.Path == "/api/v1/registration/challenge" {
+			r.mu.Lock()
+			r.challenges++
+			r.mu.Unlock()
+		}
+		if req.Method == http.MethodPost && req.URL.Path == "/api/v1/registration" {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			request := &signer.RegistrationRequest{}
+			if err := json.Unmarshal(body, request); err == nil {
+				r.mu.Lock()
+				r.requests = append(r.requests, request)
+				r.mu.Unlock()
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *recordRegistrations) last() (*signer.RegistrationRequest, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		return nil, r.challenges
+	}
+	return r.requests[len(r.requests)-1], r.challenges
+}
+
+func TestRegister(t *testing.T) {
+	recorder := &recordRegistrations{}
+	mock, server := newMockProxy(t, recorder.wrap)
+	engine := newTestEngine(t, server.URL)
+	signingKey, err := engine.Store.LoadSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := signer.PublicKeyHex(signingKey)
+
+	// Without a token, the challenge fetched from the endpoint is signed and
+	// the registration waits to be claimed.
+	registration, err := engine.Register(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, challenges := recorder.last()
+	if request == nil || challenges != 1 || request.Challenge == "" || request.Token != "" || request.PublicKey != publicKey {
+		t.Fatalf("sent %+v after %d challenges", request, challenges)
+	}
+	if valid, err := signer.VerifyRegistration(publicKey, request.Challenge, request.Signature); err != nil || !valid {
+		t.Errorf("the challenge signature does not verify: %v", err)
+	}
+	if registration.Status != signer.RegistrationPending || registration.ClaimURL == "" {
+		t.Errorf("registration is %+v, want it pending", registration)
+	}
+	if err := mock.ClaimRegistration(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := engine.RegistrationStatus(context.Background()); err != nil || status.Status != signer.RegistrationRegistered {
+		t.Errorf("registration status is %+v, %v", status, err)
+	}
+
+	// A console token is signed instead, without fetching a challenge.
+	registration, err = engine.Register(context.Background(), "console-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, challenges = recorder.last()
+	if challenges != 1 || request.Token != "console-token" || request.Challenge != "" {
+		t.Fatalf("sent %+v after %d challenges", request, challenges)
+	}
+	if valid, err := signer.VerifyRegistration(publicKey, "console-token", request.Signature); err != nil || !valid {
+		t.Errorf("the token signature does not verify: %v", err)
+	}
+	if registration.Status != signer.RegistrationRegistered {
+		t.Errorf("registration is %+v, want it registered", registration)
+	}
+}
+
+func TestRegistrationStatusUnregistered(t *testing.T) {
+	_, server := newMockProxy(t, nil)
+	engine := newTestEngine(t, server.URL)
+	status, err := engine.RegistrationStatus(context.Background())
+	if err != nil || status.Status != signer.RegistrationUnregistered {
+		t.Errorf("registration status is %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxAlerts is how many of the most recent alerts are kept in the status.
+const maxAlerts = 20
+
+// maxRuns is how many of the most recent runs are kept in the run journal.
+const maxRuns = 50
+
+// Run outcomes recorded in the run journal.
+const (
+	RunSigned        = "signed"
+	RunAlreadySigned = "already signed"
+	RunNoBlocks      = "no blocks"
+	RunFailed        = "failed"
+)
+
+// Status is a summary of the last run, kept in the data directory so it can be
+// inspected without running the signer.
+type Status struct {
+	Version            string    `json:"version"`
+	LastRun            time.Time `json:"lastRun"`
+	ClockOffsetSeconds *float64  `json:"clockOffsetSeconds,omitempty"`
+	Alerts             []*Alert  `json:"alerts,omitempty"`
+	// Endpoints is the health of each NVL Proxy endpoint at the end of the
+	// last run.
+	Endpoints []*EndpointHealth `json:"endpoints,omitempty"`
+	// LastUpdateCheck is when the signer last checked for a new release.
+	LastUpdateCheck *time.Time `json:"lastUpdateCheck,omitempty"`
+	// Runs is the run journal, oldest first.
+	Runs []*RunRecord `json:"runs,omitempty"`
+	// LastSigned is the last run that signed a block.
+	LastSigned *RunRecord `json:"lastSigned,omitempty"`
+}
+
+// RunRecord is the outcome of one run.
+type RunRecord struct {
+	Time    time.Time `json:"time"`
+	Outcome string    `json:"outcome"`
+	// ProxyBlock is the hash of the latest proxy block, if one was fetched.
+	ProxyBlock string `json:"proxyBlock,omitempty"`
+	// IndependentBlock is the hash of the block signed by the run.
+	IndependentBlock string `json:"independentBlock,omitempty"`
+	// Error is why the run failed or refused to sign.
+	Error string `json:"error,omitempty"`
+}
+
+// LastRunRecord returns the most recent run, or nil if none was recorded.
+func (s *Status) LastRunRecord() *RunRecord {
+	if len(s.Runs) == 0 {
+		return nil
+	}
+	return s.Runs[len(s.Runs)-1]
+}
+
+// RecentFailures returns up to limit of the most recent failed runs, newest
+// first.
+func (s *Status) RecentFailures(limit int) []*RunRecord {
+	var failures []*RunRecord
+	for i := len(s.Runs) - 1; i >= 0 && len(failures) < limit; i-- {
+		if s.Runs[i].Outcome == RunFailed {
+			failures = append(failures, s.Runs[i])
+		}
+	}
+	return failures
+}
+
+// Alert is an anomaly that made the signer refuse to sign and that an operator
+// should look into.
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// LoadStatus returns the status of the last run. The error wraps
+// os.ErrNotExist if the signer has not run yet.
+func (s *Store) LoadStatus() (*Status, error) {
+	fileData, err := os.ReadFile(s.path(StatusFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(Status)
+	if err := json.Unmarshal(fileData, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *Store) SaveStatus(status *Status) error {
+	return s.writeJSON(StatusFilename, status)
+}
+
+// updateStatus applies update to the saved status. Failing to save the status
+// never fails a run, so errors are only logged.
+func (e *Engine) updateStatus(update func(status *Status)) {
+	status, err := e.Store.LoadStatus()
+	if errors.Is(err, os.ErrNotExist) {
+		status = new(Status)
+	} else if err != nil {
+		e.Log.Printf("failed to load status, starting a new one: %s", err)
+		status = new(Status)
+	}
+
+	status.Version = e.Config.SignerVersion
+	update(status)
+
+	if err := e.Store.SaveStatus(status); err != nil {
+		e.Log.Printf("failed to save status: %s", err)
+	}
+}
+
+// recordRun adds the outcome of a run to the run journal.
+func (e *Engine) recordRun(result *RunResult, runErr error) {
+	record := &RunRecord{Time: time.Now().UTC()}
+	switch {
+	case errors.Is(runErr, ErrAlreadySigned):
+		record.Outcome = RunAlreadySigned
+		record.Error = runErr.Error()
+	case runErr != nil:
+		record.Outcome = RunFailed
+		record.Error = runErr.Error()
+	case result.IndependentBlock != nil:
+		record.Outcome = RunSigned
+		record.IndependentBlock = result.IndependentBlock.Seal.Proofs
+	default:
+		record.Outcome = RunNoBlocks
+	}
+	if result != nil && result.ProxyBlock != nil {
+		record.ProxyBlock = result.ProxyBlock.Seal.Proofs
+	}
+
+	e.updateStatus(func(status *Status) {
+		status.LastRun = record.Time
+		status.Runs = append(status.Runs, record)
+		if len(status.Runs) > maxRuns {
+			status.Runs = status.Runs[len(status.Runs)-maxRuns:]
+		}
+		if record.Outcome == RunSigned {
+			status.LastSigned = record
+		}
+	})
+}
+
+// UpdateCheckDue reports whether the last check for a new release was more
+// than interval ago, and if so records now as the time of the next one.
+func (e *Engine) UpdateCheckDue(interval time.Duration) (bool, error) {
+	lock, err := e.Store.Lock()
+	if err != nil {
+		return false, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	defer lock.Close()
+
+	status, err := e.Store.LoadStatus()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	now := time.Now().UTC()
+	if status != nil && status.LastUpdateCheck != nil && now.Sub(*status.LastUpdateCheck) < interval {
+		return false, nil
+	}
+	e.updateStatus(func(status *Status) {
+		status.LastUpdateCheck = &now
+	})
+	return true, nil
+}
+
+// raiseAlert logs an alert, records it in the status and, if an alert URL is
+// configured, posts it there.
+func (e *Engine) raiseAlert(ctx context.Context, message string) {
+	alert := &Alert{Time: time.Now().UTC(), Message: message}
+	e.Log.Printf("ALERT: %s\n", message)
+
+	e.updateStatus(func(status *Status) {
+		status.Alerts = append(status.Alerts, alert)
+		if len(status.Alerts) > maxAlerts {
+			status.Alerts = status.Alerts[len(status.Alerts)-maxAlerts:]
+		}
+	})
+
+	if e.Config.AlertURL != "" {
+		if err := postAlert(ctx, e.HTTP, e.Config.AlertURL, alert); err != nil {
+			e.Log.Printf("failed to post alert to %s: %s", e.Config.AlertURL, err)
+		}
+	}
+}
+
+func postAlert(ctx context.Context, httpClient *http.Client, url string, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
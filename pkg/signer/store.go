@@ -0,0 +1,259 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	SigningKeyFilename      = "signing-key"
+	PriorBlockHashFilename  = "prior-block-hash"
+	PriorProxyBlockFilename = "prior-proxy-block"
+	UnconfirmedFilename     = "unconfirmed-block"
+	StatusFilename          = "status.json"
+	SigningGuardFilename    = "signing-guard.json"
+	ChildCacheFilename      = "verified-children.json"
+	ConfigFilename          = "config.json"
+	PublicKeyFilename       = "public-key"
+	LockFilename            = "LOCK"
+)
+
+// DefaultDataDir returns the directory the signer keeps its key and state in
+// when none is configured.
+func DefaultDataDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir, err = os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("could not find working directory: %w", err)
+		}
+	}
+	return filepath.Join(configDir, "coiin", "nvl", "independent-signer"), nil
+}
+
+// Store is the signer's data directory: the signing key and the state needed
+// to continue the independent chain from one run to the next.
+type Store struct {
+	Dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func (s *Store) path(filename string) string {
+	return filepath.Join(s.Dir, filename)
+}
+
+// Lock takes an exclusive lock on the data directory so that only one signer
+// at a time can use the signing key and its state. The lock is held until the
+// returned file is closed or the process exits.
+func (s *Store) Lock() (*os.File, error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(s.path(LockFilename), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s is in use by another independent signer: %w", s.Dir, err)
+	}
+	return file, nil
+}
+
+// HasSigningKey reports whether a signing key has been generated.
+func (s *Store) HasSigningKey() (bool, error) {
+	_, err := os.Stat(s.path(SigningKeyFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) LoadSigningKey() (*ecdsa.PrivateKey, error) {
+	fileData, err := os.ReadFile(s.path(SigningKeyFilename))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.HexToECDSA(strings.TrimSpace(string(fileData)))
+}
+
+// GenerateSigningKey creates and saves a new signing key. It refuses to
+// replace an existing key.
+func (s *Store) GenerateSigningKey() (*ecdsa.PrivateKey, error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	signingKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	err = CreateFileAtomic(s.path(SigningKeyFilename), []byte(strings.ToLower(hex.EncodeToString(crypto.FromECDSA(signingKey)))), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return signingKey, nil
+}
+
+// SavePublicKey writes the public key of signingKey to the public-key file, for
+// installers and operators to copy into the Coiin Console.
+func (s *Store) SavePublicKey(signingKey *ecdsa.PrivateKey) error {
+	return WriteFileAtomic(s.path(PublicKeyFilename), []byte(PublicKeyHex(signingKey)), 0644)
+}
+
+// LoadPriorBlockHash returns the hash of the last independent block, or an
+// empty string if none was saved.
+func (s *Store) LoadPriorBlockHash() (string, error) {
+	fileData, err := s.readFile(PriorBlockHashFilename)
+	if err != nil || fileData == nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(fileData)), nil
+}
+
+// SavePriorBlockHash saves the hash of the last independent block, which also
+// clears any unconfirmed block.
+func (s *Store) SavePriorBlockHash(hash string) error {
+	if err := WriteFileAtomic(s.path(PriorBlockHashFilename), []byte(hash), 0600); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(UnconfirmedFilename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// LoadUnconfirmedBlock returns the hash of an independent block that was
+// posted without the NVL Proxy accepting it, or an empty string if there is
+// none. The proxy may still have received it, so the prior block hash is not
+// known until the chain is recovered from the proxy.
+func (s *Store) LoadUnconfirmedBlock() (string, error) {
+	fileData, err := s.readFile(UnconfirmedFilename)
+	if err != nil || fileData == nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(fileData)), nil
+}
+
+func (s *Store) SaveUnconfirmedBlock(hash string) error {
+	return WriteFileAtomic(s.path(UnconfirmedFilename), []byte(hash), 0600)
+}
+
+// LoadPriorProxyBlock returns the last attested proxy block, or nil if none was
+// saved.
+func (s *Store) LoadPriorProxyBlock() (*AttestedProxyBlock, error) {
+	fileData, err := s.readFile(PriorProxyBlockFilename)
+	if err != nil || fileData == nil {
+		return nil, err
+	}
+
+	prior := new(AttestedProxyBlock)
+	if err := json.Unmarshal(fileData, prior); err != nil {
+		return nil, err
+	}
+	return prior, nil
+}
+
+// SavePriorProxyBlock records block as the last attested proxy block, prior
+// being the one attested before it.
+func (s *Store) SavePriorProxyBlock(block *NVLBlock, prior *AttestedProxyBlock) error {
+	return s.writeJSON(PriorProxyBlockFilename, NewAttestedProxyBlock(block, prior))
+}
+
+// LoadSigningGuard returns the signing guard, or an empty guard if none was
+// saved.
+func (s *Store) LoadSigningGuard() (*SigningGuard, error) {
+	guard, err := ReadSigningGuard(s.path(SigningGuardFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return NewSigningGuard(), nil
+	}
+	return guard, err
+}
+
+func (s *Store) SaveSigningGuard(guard *SigningGuard) error {
+	return guard.Write(s.path(SigningGuardFilename))
+}
+
+// Check makes sure every file in the data directory can be parsed before
+// anything is signed, and explains how to recover when one cannot.
+func (s *Store) Check() error {
+	if data, err := s.readFile(SigningKeyFilename); err != nil {
+		return err
+	} else if data != nil {
+		if _, err := crypto.HexToECDSA(strings.TrimSpace(string(data))); err != nil {
+			return fmt.Errorf("signing key %s is corrupt (%s). Restore it from a backup. If there is no backup, move the file "+
+				"aside so a new key is generated, then register the new public key in the Coiin Console", s.path(SigningKeyFilename), err)
+		}
+	}
+
+	if data, err := s.readFile(PriorBlockHashFilename); err != nil {
+		return err
+	} else if data != nil && !isBlockHash(strings.TrimSpace(string(data))) {
+		return fmt.Errorf("prior block hash %s is corrupt. Move the file aside to start a new independent chain", s.path(PriorBlockHashFilename))
+	}
+
+	if _, err := s.LoadPriorProxyBlock(); err != nil {
+		return fmt.Errorf("previously attested proxy block %s is corrupt. Move the file aside, "+
+			"it will be recreated the next time a block is signed", s.path(PriorProxyBlockFilename))
+	}
+
+	if _, err := s.LoadSigningGuard(); err != nil {
+		return fmt.Errorf("signing guard %s is corrupt (%s). Restore it with \"guard import\" from an export, "+
+			"or move the file aside to start an empty guard", s.path(SigningGuardFilename), err)
+	}
+
+	if _, err := s.LoadChildCache(); err != nil {
+		return fmt.Errorf("verified child blocks %s are corrupt. Move the file aside, "+
+			"child blocks will be verified again", s.path(ChildCacheFilename))
+	}
+
+	return nil
+}
+
+// readFile returns the contents of a data directory file, or nil if it does
+// not exist.
+func (s *Store) readFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *Store) writeJSON(filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.path(filename), data, 0600)
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ErrSupplyAnomaly is returned when a proxy block reports a coiinSupply that
+// fails one of the supply invariants.
+var ErrSupplyAnomaly = errors.New("anomalous NVL Proxy coiinSupply")
+
+// ParseSupply parses a coiinSupply, which must be a non-negative decimal
+// integer with no sign, spaces or exponent.
+func ParseSupply(supply string) (*big.Int, error) {
+	if supply == "" {
+		return nil, errors.New("supply is empty")
+	}
+	for _, c := range supply {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("supply %q is not a decimal integer", supply)
+		}
+	}
+	value, ok := new(big.Int).SetString(supply, 10)
+	if !ok {
+		return nil, fmt.Errorf("supply %q is not a decimal integer", supply)
+	}
+	return value, nil
+}
+
+// CheckSupply checks that block's coiinSupply parses and, compared with the
+// previously attested proxy block, has not decreased and has not grown faster
+// than maxIncreasePerHour of proxy time. A nil maxIncreasePerHour disables the
+// growth check. Blocks without a coiinSupply, which the block formats allow,
+// are not checked, and the next block with one is compared with the last
+// attested block that had one.
+func CheckSupply(block *NVLBlock, prior *AttestedProxyBlock, maxIncreasePerHour *big.Int) error {
+	if block.Header.CoiinSupply == "" {
+		return nil
+	}
+	supply, err := ParseSupply(block.Header.CoiinSupply)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSupplyAnomaly, err)
+	}
+	if prior == nil || prior.Hash == block.Seal.Proofs {
+		return nil
+	}
+	prior = prior.supplyBlock()
+	if prior.CoiinSupply == "" {
+		return nil
+	}
+	priorSupply, err := ParseSupply(prior.CoiinSupply)
+	if err != nil {
+		return nil
+	}
+
+	increase := new(big.Int).Sub(supply, priorSupply)
+	if increase.Sign() < 0 {
+		return fmt.Errorf("%w: supply decreased from %s in block %s to %s", ErrSupplyAnomaly, priorSupply, prior.Hash, supply)
+	}
+
+	if maxIncreasePerHour == nil {
+		return nil
+	}
+	timestamp, err := ParseBlockTimestamp(block.Header.Timestamp)
+	if err != nil {
+		return nil
+	}
+	priorTimestamp, err := ParseBlockTimestamp(prior.Timestamp)
+	if err != nil {
+		return nil
+	}
+	// Allow at least one hour of emission so that blocks close together are
+	// not held to a tiny bound.
+	hours := timestamp.Sub(priorTimestamp).Hours()
+	if hours < 1 {
+		hours = 1
+	}
+	bound, _ := new(big.Float).Mul(new(big.Float).SetInt(maxIncreasePerHour), big.NewFloat(hours)).Int(nil)
+	if increase.Cmp(bound) > 0 {
+		return fmt.Errorf("%w: supply grew by %s since block %s, more than the %s allowed over %s",
+			ErrSupplyAnomaly, increase, prior.Hash, bound, timestamp.Sub(priorTimestamp).Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func supplyBlock(supply string) *NVLBlock {
+	return &NVLBlock{
+		Header: &NVLBlockHeader{Timestamp: "1700003600", CoiinSupply: supply},
+		Seal:   &NVLBlockSeal{Proofs: "next"},
+	}
+}
+
+func TestCheckSupply(t *testing.T) {
+	prior := &AttestedProxyBlock{Hash: "prior", Timestamp: "1700000000", CoiinSupply: "1000"}
+	tests := []struct {
+		name   string
+		supply string
+		prior  *AttestedProxyBlock
+		max    *big.Int
+		ok     bool
+	}{
+		{"no supply", "", prior, big.NewInt(10), true},
+		{"no supply without prior", "", nil, nil, true},
+		{"first block", "1000", nil, nil, true},
+		{"prior without supply", "5", &AttestedProxyBlock{Hash: "prior", Timestamp: "1700000000"}, nil, true},
+		{"unchanged", "1000", prior, big.NewInt(10), true},
+		{"growth within bound", "1010", prior, big.NewInt(10), true},
+		{"growth over bound", "1011", prior, big.NewInt(10), false},
+		{"unbounded growth", "99999", prior, nil, true},
+		{"decrease", "999", prior, nil, false},
+		{"not a number", "1e3", prior, nil, false},
+		{"negative", "-1", nil, nil, false},
+	}
+	for _, test := range tests {
+		err := CheckSupply(supplyBlock(test.supply), test.prior, test.max)
+		if test.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.ok && !errors.Is(err, ErrSupplyAnomaly) {
+			t.Errorf("%s: got %v, want ErrSupplyAnomaly", test.name, err)
+		}
+	}
+}
+
+func TestCheckSupplyAfterBlockWithoutSupply(t *testing.T) {
+	// A block without a supply must not wipe the baseline that the next
+	// block is checked against.
+	store := NewStore(t.TempDir())
+	blocks := []struct {
+		hash, timestamp, supply string
+		ok                      bool
+	}{
+		{"first", "1700000000", "1000", true},
+		{"empty", "1700003600", "", true},
+		{"lower", "1700007200", "999", false},
+		{"higher", "1700007200", "1030", false},
+		{"within bound", "1700007200", "1020", true},
+	}
+	for _, test := range blocks {
+		block := &NVLBlock{
+			Header: &NVLBlockHeader{Timestamp: test.timestamp, CoiinSupply: test.supply},
+			Seal:   &NVLBlockSeal{Proofs: test.hash},
+		}
+		prior, err := store.LoadPriorProxyBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckSupply(block, prior, big.NewInt(10))
+		if test.ok && err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.hash, err)
+		}
+		if !test.ok {
+			if !errors.Is(err, ErrSupplyAnomaly) {
+				t.Fatalf("%s: got %v, want ErrSupplyAnomaly", test.hash, err)
+			}
+			continue
+		}
+		if err := store.SavePriorProxyBlock(block, prior); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrImplausibleTimestamp is returned when a proxy block's timestamp fails one
+// of the sanity checks performed before signing.
+var ErrImplausibleTimestamp = errors.New("implausible NVL Proxy block timestamp")
+
+// AttestedProxyBlock records the proxy block that was last attested to, so
+// the next run can check that the proxy chain is moving forward.
+type AttestedProxyBlock struct {
+	Hash        string `json:"hash"`
+	Timestamp   string `json:"timestamp"`
+	CoiinSupply string `json:"coiinSupply,omitempty"`
+	// SupplyBlock is the last attested block that had a coiinSupply, when
+	// this one has none, so that a block without a supply does not reset the
+	// supply checks.
+	SupplyBlock *AttestedProxyBlock `json:"supplyBlock,omitempty"`
+}
+
+// NewAttestedProxyBlock records block as attested after prior, the block
+// attested before it.
+func NewAttestedProxyBlock(block *NVLBlock, prior *AttestedProxyBlock) *AttestedProxyBlock {
+	attested := &AttestedProxyBlock{
+		Hash:        block.Seal.Proofs,
+		Timestamp:   block.Header.Timestamp,
+		CoiinSupply: block.Header.CoiinSupply,
+	}
+	if attested.CoiinSupply == "" && prior != nil {
+		if supplyBlock := prior.supplyBlock(); supplyBlock.CoiinSupply != "" {
+			attested.SupplyBlock = supplyBlock
+		}
+	}
+	return attested
+}
+
+// supplyBlock returns the last attested block with a coiinSupply, which is b
+// itself unless it has none.
+func (b *AttestedProxyBlock) supplyBlock() *AttestedProxyBlock {
+	if b.SupplyBlock != nil {
+		return b.SupplyBlock
+	}
+	return b
+}
+
+// ParseBlockTimestamp parses a block timestamp, which is normally unix seconds
+// but is also accepted in RFC 3339 form.
+func ParseBlockTimestamp(timestamp string) (time.Time, error) {
+	timestamp = strings.TrimSpace(timestamp)
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, timestamp)
+}
+
+// CheckBlockTimestamp checks that block's timestamp parses, is not further
+// in the future than maxFutureSkew, is not older than maxBlockAge and is newer
+// than the previously attested proxy block. A zero duration disables the
+// corresponding check.
+func CheckBlockTimestamp(block *NVLBlock, prior *AttestedProxyBlock, now time.Time, maxFutureSkew, maxBlockAge time.Duration) error {
+	timestamp, err := ParseBlockTimestamp(block.Header.Timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: could not parse %q", ErrImplausibleTimestamp, block.Header.Timestamp)
+	}
+
+	if maxFutureSkew > 0 && timestamp.After(now.Add(maxFutureSkew)) {
+		return fmt.Errorf("%w: %s is %s in the future", ErrImplausibleTimestamp, timestamp.UTC().Format(time.RFC3339), timestamp.Sub(now).Round(time.Second))
+	}
+
+	if maxBlockAge > 0 && now.Sub(timestamp) > maxBlockAge {
+		return fmt.Errorf("%w: %s is older than %s", ErrImplausibleTimestamp, timestamp.UTC().Format(time.RFC3339), maxBlockAge)
+	}
+
+	if prior != nil && prior.Hash != block.Seal.Proofs {
+		priorTimestamp, err := ParseBlockTimestamp(prior.Timestamp)
+		if err == nil && !timestamp.After(priorTimestamp) {
+			return fmt.Errorf("%w: %s is not newer than previously attested block %s (%s)",
+				ErrImplausibleTimestamp, timestamp.UTC().Format(time.RFC3339), prior.Hash, priorTimestamp.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ErrPinMismatch is returned when none of the certificates presented by a
+// server match a pinned SPKI hash.
+var ErrPinMismatch = errors.New("server certificate does not match any pinned public key")
+
+// TLSConfig controls how the signer connects to the NVL Proxy and other HTTP
+// endpoints.
+type TLSConfig struct {
+	// HTTPProxy is the URL of the proxy requests are sent through. When it is
+	// empty the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are
+	// used.
+	HTTPProxy string
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to
+	// the system ones, for example the one of an intercepting proxy.
+	CAFile string
+	// PinnedSPKI are base64 encoded SHA-256 hashes of the DER encoded subject
+	// public key info of certificates to pin, optionally prefixed with
+	// "sha256/". A connection is accepted if any certificate the server
+	// presents matches one of them. The engine only pins the NVL Proxy.
+	PinnedSPKI []string
+	// ClientCertFile and ClientKeyFile are a PEM certificate and key presented
+	// to servers that require mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// NewTransport returns an HTTP transport configured by config.
+func NewTransport(config TLSConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no certificates", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.PinnedSPKI) > 0 {
+		pins := make(map[string]bool, len(config.PinnedSPKI))
+		for _, pin := range config.PinnedSPKI {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			hash, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid SPKI pin %q, expected a base64 encoded SHA-256 hash", pin)
+			}
+			pins[string(hash)] = true
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, cert := range state.PeerCertificates {
+				hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[string(hash[:])] {
+					return nil
+				}
+			}
+			return ErrPinMismatch
+		}
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// SPKIHash returns the pin for cert in the format accepted by
+// TLSConfig.PinnedSPKI.
+func SPKIHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnginePinsOnlyTheProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hash":"0x01"}`))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.BaseURLs = []string{server.URL}
+	config.TLS.CAFile = caFile
+	config.TLS.PinnedSPKI = []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	engine, err := NewEngine(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.Client.FetchLatestBlockHash(context.Background()); !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("NVL Proxy request: got %v, want ErrPinMismatch", err)
+	}
+	resp, err := engine.HTTP.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unpinned request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	config.TLS.PinnedSPKI = []string{SPKIHash(server.Certificate())}
+	if engine, err = NewEngine(config, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Client.FetchLatestBlockHash(context.Background()); err != nil {
+		t.Errorf("pinned NVL Proxy request failed: %v", err)
+	}
+}
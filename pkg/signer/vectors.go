@@ -0,0 +1,167 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Vector is a canonical-encoding conformance test vector: a block as it
+// appears on the wire, and the canonical signing payload, Keccak-256 hash and
+// signature it must produce. See docs/signing-payload.md.
+type Vector struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Block       json.RawMessage `json:"block"`
+	Canonical   string          `json:"canonical"`
+	Hash        string          `json:"hash"`
+	Signature   string          `json:"signature"`
+}
+
+// VectorSet is a corpus of vectors signed with a fixed private key. The key is
+// published with the vectors and must never be used for anything else.
+type VectorSet struct {
+	PrivateKey string    `json:"privateKey"`
+	PublicKey  string    `json:"publicKey"`
+	Vectors    []*Vector `json:"vectors"`
+}
+
+// NewVector computes the expected outputs for block with signingKey.
+func NewVector(name, description string, block json.RawMessage, signingKey *ecdsa.PrivateKey) (*Vector, error) {
+	parsed, err := parseVectorBlock(block)
+	if err != nil {
+		return nil, fmt.Errorf("vector %s: %w", name, err)
+	}
+	canonical, err := parsed.MarshalForSigning()
+	if err != nil {
+		return nil, fmt.Errorf("vector %s: %w", name, err)
+	}
+	hash, signature, err := SignBlock(signingKey, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("vector %s: %w", name, err)
+	}
+
+	return &Vector{
+		Name:        name,
+		Description: description,
+		Block:       block,
+		Canonical:   string(canonical),
+		Hash:        hash,
+		Signature:   signature,
+	}, nil
+}
+
+// Check recomputes every vector and returns an error describing each one that
+// no longer matches.
+func (set *VectorSet) Check() error {
+	signingKey, err := set.signingKey()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, vector := range set.Vectors {
+		if err := vector.check(signingKey); err != nil {
+			errs = append(errs, fmt.Errorf("vector %s: %w", vector.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// signingKey returns the private key of the set, after checking that it
+// matches the published public key.
+func (set *VectorSet) signingKey() (*ecdsa.PrivateKey, error) {
+	signingKey, err := crypto.HexToECDSA(set.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vector private key: %w", err)
+	}
+	if publicKey := PublicKeyHex(signingKey); publicKey != set.PublicKey {
+		return nil, fmt.Errorf("vector public key %s does not match private key (%s)", set.PublicKey, publicKey)
+	}
+	return signingKey, nil
+}
+
+func (v *Vector) check(signingKey *ecdsa.PrivateKey) error {
+	expected, err := NewVector(v.Name, v.Description, v.Block, signingKey)
+	if err != nil {
+		return err
+	}
+	if expected.Canonical != v.Canonical {
+		return fmt.Errorf("canonical payload is %s, expected %s", expected.Canonical, v.Canonical)
+	}
+	if expected.Hash != v.Hash {
+		return fmt.Errorf("hash is %s, expected %s", expected.Hash, v.Hash)
+	}
+	if expected.Signature != v.Signature {
+		return fmt.Errorf("signature is %s, expected %s", expected.Signature, v.Signature)
+	}
+
+	block, err := parseVectorBlock(v.Block)
+	if err != nil {
+		return err
+	}
+
+	// Blocks the signer creates itself have no raw payload. Unless the vector
+	// has fields the struct cannot hold, encoding the struct must give the
+	// same payload as canonicalizing the raw JSON.
+	unknown, err := CheckBlockFormat(block)
+	if err != nil {
+		return err
+	}
+	if len(unknown) == 0 {
+		format, err := LookupBlockFormat(block.Version)
+		if err != nil {
+			return err
+		}
+		canonical, err := format.MarshalForSigning(block)
+		if err != nil {
+			return err
+		}
+		if string(canonical) != v.Canonical {
+			return fmt.Errorf("struct payload is %s, expected %s", canonical, v.Canonical)
+		}
+	}
+
+	block.Seal.Signature = v.Signature
+	if valid, err := VerifyBlock(crypto.FromECDSAPub(&signingKey.PublicKey), block); err != nil {
+		return err
+	} else if !valid {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+// parseVectorBlock decodes a vector block, which has no seal, keeping its raw
+// payload the way ParseNVLBlock does.
+func parseVectorBlock(data json.RawMessage) (*NVLBlock, error) {
+	block := new(NVLBlock)
+	if err := json.Unmarshal(data, block); err != nil {
+		return nil, err
+	}
+	if block.Header == nil {
+		return nil, errors.New("block has no header")
+	}
+	block.Seal = new(NVLBlockSeal)
+	block.raw = string(data)
+	return block, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package signer
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := new(VectorSet)
+	if err := json.Unmarshal(data, set); err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := set.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Vectors) == 0 {
+		t.Fatal("no vectors")
+	}
+
+	for _, vector := range set.Vectors {
+		vector := vector
+		t.Run(vector.Name, func(t *testing.T) {
+			if err := vector.check(signingKey); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
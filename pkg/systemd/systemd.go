@@ -0,0 +1,404 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package systemd installs the independent signer as a systemd service run by
+// a timer, either system wide or for the current user.
+package systemd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Name is the name of the service and timer units.
+	Name = "independent-signer"
+	// SystemDataDir is the data directory of a system wide install. It is
+	// managed by systemd as the StateDirectory of the service.
+	SystemDataDir = "/var/lib/" + Name
+	// SystemUnitDir is where system wide units are installed.
+	SystemUnitDir = "/etc/systemd/system"
+	// SystemExecPath is where a system wide install copies the executable to,
+	// unless it is already installed under /usr.
+	SystemExecPath = "/usr/local/bin/" + Name
+	// DefaultInterval is how often the timer runs the signer.
+	DefaultInterval = 30 * time.Minute
+)
+
+// Options describe an install.
+type Options struct {
+	// System installs the units system wide, running the signer as a
+	// dynamic user with its own data directory. Otherwise the units are
+	// installed for the current user, and use the user's data directory.
+	System bool
+	// ExecPath is the independent-signer executable.
+	ExecPath string
+	// Interval is how often the timer runs the signer.
+	Interval time.Duration
+	// RunAtBoot runs the signer shortly after boot. Otherwise the first run
+	// is one Interval after the timer starts.
+	RunAtBoot bool
+	// OnACPower skips runs while the computer is on battery.
+	OnACPower bool
+	// Args are extra arguments passed to the signer.
+	Args []string
+}
+
+var serviceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description=NVL independent signer
+Documentation=https://github.com/Coiin-Blockchain/nvl-independent-signer
+Wants=network-online.target
+After=network-online.target
+{{- if .OnACPower}}
+ConditionACPower=true
+{{- end}}
+
+[Service]
+Type=oneshot
+ExecStart={{.ExecStart}}
+{{- if .System}}
+DynamicUser=yes
+StateDirectory={{.Name}}
+StateDirectoryMode=0700
+ProtectSystem=strict
+ProtectHome=yes
+PrivateTmp=yes
+PrivateDevices=yes
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectKernelLogs=yes
+ProtectControlGroups=yes
+ProtectClock=yes
+ProtectHostname=yes
+RestrictNamespaces=yes
+CapabilityBoundingSet=
+{{- end}}
+UMask=0077
+NoNewPrivileges=yes
+LockPersonality=yes
+MemoryDenyWriteExecute=yes
+RestrictRealtime=yes
+RestrictSUIDSGID=yes
+RestrictAddressFamilies=AF_INET AF_INET6 AF_UNIX
+SystemCallArchitectures=native
+SystemCallFilter=@system-service
+SystemCallFilter=~@privileged
+`))
+
+var timerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description=Run the NVL independent signer periodically
+
+[Timer]
+{{- if .RunAtBoot}}
+OnBootSec=2min
+{{- else}}
+OnActiveSec={{.Interval}}
+{{- end}}
+OnUnitActiveSec={{.Interval}}
+RandomizedDelaySec=1min
+
+[Install]
+WantedBy=timers.target
+`))
+
+// ServiceUnit returns the contents of the service unit.
+func ServiceUnit(options Options) (string, error) {
+	args := []string{options.ExecPath}
+	if options.System {
+		args = append(args, "-dataDir", SystemDataDir)
+	}
+	args = append(args, options.Args...)
+	for i, arg := range args {
+		args[i] = quote(arg)
+	}
+
+	return execute(serviceTemplate, map[string]interface{}{
+		"Name":      Name,
+		"System":    options.System,
+		"ExecStart": strings.Join(args, " "),
+		"OnACPower": options.OnACPower,
+	})
+}
+
+// TimerUnit returns the contents of the timer unit.
+func TimerUnit(options Options) (string, error) {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return execute(timerTemplate, map[string]interface{}{
+		"System":    options.System,
+		"Interval":  timeSpan(interval),
+		"RunAtBoot": options.RunAtBoot,
+	})
+}
+
+// WriteUnits writes the service and timer units to dir.
+func WriteUnits(dir string, options Options) error {
+	service, err := ServiceUnit(options)
+	if err != nil {
+		return err
+	}
+	timer, err := TimerUnit(options)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, Name+".service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, Name+".timer"), []byte(timer), 0644)
+}
+
+// ReadUnits reads the schedule back from the units in dir. ExecPath and Args
+// are not read back.
+func ReadUnits(dir string, system bool) (*Options, error) {
+	service, err := unitValues(filepath.Join(dir, Name+".service"))
+	if err != nil {
+		return nil, err
+	}
+	timer, err := unitValues(filepath.Join(dir, Name+".timer"))
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseTimeSpan(timer["OnUnitActiveSec"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid OnUnitActiveSec in %s.timer: %w", Name, err)
+	}
+	return &Options{
+		System:    system,
+		Interval:  interval,
+		RunAtBoot: timer["OnBootSec"] != "",
+		OnACPower: service["ConditionACPower"] == "true",
+	}, nil
+}
+
+// unitValues returns the settings of a unit file. Of a setting given more than
+// once, the last value is returned.
+func unitValues(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return values, nil
+}
+
+// UnitDir returns the directory units are installed in.
+func UnitDir(system bool) (string, error) {
+	if system {
+		return SystemUnitDir, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user"), nil
+}
+
+// Install writes the units, enables the timer and runs the service once so
+// that the signing key is generated.
+func Install(options Options) error {
+	if options.System && os.Geteuid() != 0 {
+		return errors.New("a system wide install must be run as root")
+	}
+	dir, err := UnitDir(options.System)
+	if err != nil {
+		return err
+	}
+	if err := WriteUnits(dir, options); err != nil {
+		return err
+	}
+
+	if err := systemctl(options.System, "daemon-reload"); err != nil {
+		removeUnits(dir)
+		return err
+	}
+	if err := systemctl(options.System, "enable", "--now", Name+".timer"); err != nil {
+		removeUnits(dir)
+		_ = systemctl(options.System, "daemon-reload")
+		return err
+	}
+	// The first run generates the signing key. It usually fails to post a
+	// block because the key is not registered yet, which is expected.
+	_ = systemctl(options.System, "start", Name+".service")
+	return nil
+}
+
+func removeUnits(dir string) error {
+	for _, unit := range []string{Name + ".timer", Name + ".service"} {
+		if err := os.Remove(filepath.Join(dir, unit)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Uninstall stops and removes the units. The data directory, and with it the
+// signing key, is left in place.
+func Uninstall(system bool) error {
+	dir, err := UnitDir(system)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, Name+".timer")); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is not installed in %s", Name, dir)
+	}
+
+	if err := systemctl(system, "disable", "--now", Name+".timer"); err != nil {
+		return err
+	}
+	if err := removeUnits(dir); err != nil {
+		return err
+	}
+	return systemctl(system, "daemon-reload")
+}
+
+// TimerStatus is what systemd reports about the timer.
+type TimerStatus struct {
+	System      bool
+	Enabled     string
+	ActiveState string
+	LastRun     string
+	NextRun     string
+	// Result is the result of the last run of the service, e.g. "success".
+	Result string
+}
+
+// Status returns the status of the installed timer, or nil if it is not
+// installed.
+func Status(system bool) (*TimerStatus, error) {
+	dir, err := UnitDir(system)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, Name+".timer")); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	timer, err := show(system, Name+".timer", "UnitFileState", "ActiveState", "LastTriggerUSec", "NextElapseUSecRealtime")
+	if err != nil {
+		return nil, err
+	}
+	service, err := show(system, Name+".service", "Result")
+	if err != nil {
+		return nil, err
+	}
+	return &TimerStatus{
+		System:      system,
+		Enabled:     timer["UnitFileState"],
+		ActiveState: timer["ActiveState"],
+		LastRun:     timer["LastTriggerUSec"],
+		NextRun:     timer["NextElapseUSecRealtime"],
+		Result:      service["Result"],
+	}, nil
+}
+
+// Start runs the service now, without waiting for it to finish.
+func Start(system bool) error {
+	return systemctl(system, "start", "--no-block", Name+".service")
+}
+
+func show(system bool, unit string, properties ...string) (map[string]string, error) {
+	args := []string{"show", unit, "--property=" + strings.Join(properties, ",")}
+	if !system {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("systemctl %s: %w", strings.Join(args, " "), err)
+	}
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, value, ok := strings.Cut(line, "="); ok {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+func systemctl(system bool, args ...string) error {
+	if !system {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// timeSpan formats d as a systemd time span.
+func timeSpan(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dmin", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", (d+time.Second-1)/time.Second)
+}
+
+// parseTimeSpan parses the time spans written by timeSpan, and the ones
+// time.ParseDuration understands.
+func parseTimeSpan(span string) (time.Duration, error) {
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{{"min", time.Minute}, {"s", time.Second}} {
+		if number, ok := strings.CutSuffix(span, unit.suffix); ok {
+			if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+				return time.Duration(n) * unit.duration, nil
+			}
+		}
+	}
+	return time.ParseDuration(span)
+}
+
+// quote quotes arg for an ExecStart line if it needs it.
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+		return arg
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + replacer.Replace(arg) + `"`
+}
@@ -0,0 +1,378 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+// Package update replaces the signer executable with a newer release.
+//
+// A release is described by a JSON metadata file listing, for every platform,
+// the executable, its SHA-256 and a detached signature. The signature is made
+// with the release key over the release version, the platform and the
+// Keccak-256 hash of the executable (see ReleaseHash), in the same format as
+// block signatures, and is checked against the release public key compiled
+// into the signer before anything is replaced. A signed executable therefore
+// cannot be served as another version or for another platform.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+// DefaultURL is where the metadata of the latest release is published.
+const DefaultURL = "https://github.com/Coiin-Blockchain/nvl-independent-signer/releases/latest/download/release.json"
+
+// MetadataFilename is the name of the release metadata file.
+const MetadataFilename = "release.json"
+
+// maxExecutableSize bounds the download of a release executable.
+const maxExecutableSize = 256 << 20
+
+// startupTimeout bounds how long a new executable may take to report its
+// version.
+const startupTimeout = 30 * time.Second
+
+var (
+	// ErrNoReleaseKey is returned when the signer was built without a release
+	// public key, so no release can be trusted.
+	ErrNoReleaseKey = errors.New("this build of the signer has no release public key and cannot update itself")
+	// ErrBadSignature is returned when an executable is not signed by the
+	// release key.
+	ErrBadSignature = errors.New("release signature is invalid")
+)
+
+// Release is the release metadata.
+type Release struct {
+	Version string `json:"version"`
+	// Executables are keyed by platform, see Platform.
+	Executables map[string]*Executable `json:"executables"`
+}
+
+// Executable is the signer built for one platform. URLs may be relative to the
+// metadata file.
+type Executable struct {
+	URL          string `json:"url"`
+	SHA256       string `json:"sha256"`
+	SignatureURL string `json:"signatureURL"`
+}
+
+// Result describes an update.
+type Result struct {
+	From string
+	To   string
+	// Updated is false when the running version is already the latest.
+	Updated bool
+}
+
+// Platform returns the platform key of the running signer, e.g. linux_amd64.
+func Platform() string {
+	return runtime.GOOS + "_" + runtime.GOARCH
+}
+
+// releaseDomain separates release signatures from other signatures in the
+// same format.
+const releaseDomain = "NVL independent signer release\n"
+
+// ReleaseHash returns the hash release signatures are made over. Each field
+// is hashed on its own, so that no two releases encode to the same bytes.
+func ReleaseHash(version, platform string, executable []byte) []byte {
+	return crypto.Keccak256(
+		[]byte(releaseDomain),
+		crypto.Keccak256([]byte(version)),
+		crypto.Keccak256([]byte(platform)),
+		crypto.Keccak256(executable),
+	)
+}
+
+// Sign returns the hex encoded detached signature of executable as the
+// release version for platform.
+func Sign(releaseKey *ecdsa.PrivateKey, version, platform string, executable []byte) (string, error) {
+	signature, err := crypto.Sign(ReleaseHash(version, platform, executable), releaseKey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+// Verify checks that signature is a signature by publicKey of executable as
+// the release version for platform.
+func Verify(publicKey []byte, version, platform string, executable []byte, signature string) error {
+	sig, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+	if len(sig) != 65 {
+		return fmt.Errorf("%w: signature is %d bytes, expected 65", ErrBadSignature, len(sig))
+	}
+	if !crypto.VerifySignature(publicKey, ReleaseHash(version, platform, executable), sig[:64]) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// CompareVersions compares two versions of the form v1.2.3, ignoring anything
+// after a "-" or "+". It returns -1, 0 or 1.
+func CompareVersions(a, b string) (int, error) {
+	partsA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	partsB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range partsA {
+		if partsA[i] < partsB[i] {
+			return -1, nil
+		} else if partsA[i] > partsB[i] {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(version string) ([3]int, error) {
+	var parts [3]int
+	core := strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	fields := strings.Split(core, ".")
+	if len(fields) != 3 {
+		return parts, fmt.Errorf("invalid version %q", version)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("invalid version %q", version)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// Updater replaces ExecPath with the latest release published at URL.
+type Updater struct {
+	// URL is the release metadata. It defaults to DefaultURL.
+	URL string
+	// PublicKey is the uncompressed release public key.
+	PublicKey []byte
+	HTTP      *http.Client
+	// ExecPath is the executable to replace.
+	ExecPath string
+	// Version is the version of ExecPath.
+	Version string
+}
+
+// Check fetches the release metadata and reports whether it is newer than
+// the running version.
+func (u *Updater) Check(ctx context.Context) (*Release, bool, error) {
+	data, err := u.get(ctx, u.metadataURL())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch release metadata: %w", err)
+	}
+	release := new(Release)
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, false, fmt.Errorf("invalid release metadata: %w", err)
+	}
+	cmp, err := CompareVersions(release.Version, u.Version)
+	if err != nil {
+		return nil, false, err
+	}
+	return release, cmp > 0, nil
+}
+
+// Update installs the latest release if it is newer than the running version.
+// The new executable is verified before it replaces ExecPath, and the
+// previous one is restored if the new one fails to start. The previous
+// executable is kept next to ExecPath with an .old suffix.
+func (u *Updater) Update(ctx context.Context) (*Result, error) {
+	if len(u.PublicKey) == 0 {
+		return nil, ErrNoReleaseKey
+	}
+	release, newer, err := u.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{From: u.Version, To: release.Version}
+	if !newer {
+		result.To = u.Version
+		return result, nil
+	}
+
+	executable, err := u.download(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	if err := Swap(u.ExecPath, executable, release.Version); err != nil {
+		return nil, err
+	}
+	result.Updated = true
+	return result, nil
+}
+
+// download fetches and verifies the executable of the running platform.
+func (u *Updater) download(ctx context.Context, release *Release) ([]byte, error) {
+	entry := release.Executables[Platform()]
+	if entry == nil {
+		return nil, fmt.Errorf("release %s has no executable for %s", release.Version, Platform())
+	}
+	executableURL, err := u.resolve(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	signatureURL, err := u.resolve(entry.SignatureURL)
+	if err != nil {
+		return nil, err
+	}
+
+	executable, err := u.get(ctx, executableURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", executableURL, err)
+	}
+	digest := sha256.Sum256(executable)
+	if !strings.EqualFold(hex.EncodeToString(digest[:]), entry.SHA256) {
+		return nil, fmt.Errorf("the SHA-256 of %s does not match the release metadata", executableURL)
+	}
+	signature, err := u.get(ctx, signatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", signatureURL, err)
+	}
+	if err := Verify(u.PublicKey, release.Version, Platform(), executable, string(signature)); err != nil {
+		return nil, fmt.Errorf("%s: %w", executableURL, err)
+	}
+	return executable, nil
+}
+
+func (u *Updater) metadataURL() string {
+	if u.URL == "" {
+		return DefaultURL
+	}
+	return u.URL
+}
+
+func (u *Updater) resolve(ref string) (string, error) {
+	if ref == "" {
+		return "", errors.New("release metadata is missing a URL")
+	}
+	base, err := url.Parse(u.metadataURL())
+	if err != nil {
+		return "", err
+	}
+	target, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(target).String(), nil
+}
+
+func (u *Updater) get(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := u.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxExecutableSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxExecutableSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", target, maxExecutableSize)
+	}
+	return data, nil
+}
+
+// Swap replaces execPath with executable, then runs "execPath version" and
+// restores the previous executable unless it reports version.
+func Swap(execPath string, executable []byte, version string) error {
+	newPath, oldPath := execPath+".new", execPath+".old"
+	if err := signer.WriteFileAtomic(newPath, executable, 0755); err != nil {
+		return fmt.Errorf("failed to write the new executable: %w", err)
+	}
+	defer os.Remove(newPath)
+
+	// A running executable cannot be replaced on Windows, but it can be
+	// renamed, so the current one is moved aside first.
+	if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(execPath, oldPath); err != nil {
+		return fmt.Errorf("failed to move the current executable aside: %w", err)
+	}
+	if err := os.Rename(newPath, execPath); err != nil {
+		return rollback(execPath, oldPath, fmt.Errorf("failed to install the new executable: %w", err))
+	}
+
+	reported, err := startupVersion(execPath)
+	if err != nil {
+		return rollback(execPath, oldPath, fmt.Errorf("the new executable failed to start: %w", err))
+	}
+	if reported != version {
+		return rollback(execPath, oldPath, fmt.Errorf("the new executable reports version %q, expected %q", reported, version))
+	}
+	return nil
+}
+
+func rollback(execPath, oldPath string, cause error) error {
+	if err := os.Rename(oldPath, execPath); err != nil {
+		return fmt.Errorf("%w, and restoring the previous executable from %s failed: %s", cause, oldPath, err)
+	}
+	return fmt.Errorf("%w, the previous executable was restored", cause)
+}
+
+func startupVersion(execPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, execPath, "version")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
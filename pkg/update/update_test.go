@@ -0,0 +1,224 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// versionEnv makes the test binary act as a signer executable that reports
+// the version it holds.
+const versionEnv = "UPDATE_TEST_REPORT_VERSION"
+
+func TestMain(m *testing.M) {
+	if version := os.Getenv(versionEnv); version != "" {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testRelease is a release served by a local release server.
+type testRelease struct {
+	version    string
+	executable []byte
+	// signedVersion and signedPlatform default to version and Platform().
+	signedVersion  string
+	signedPlatform string
+	signingKey     *ecdsa.PrivateKey
+	sha256         string
+}
+
+func (r *testRelease) serve(t *testing.T) string {
+	t.Helper()
+	signedVersion, signedPlatform := r.signedVersion, r.signedPlatform
+	if signedVersion == "" {
+		signedVersion = r.version
+	}
+	if signedPlatform == "" {
+		signedPlatform = Platform()
+	}
+	signature, err := Sign(r.signingKey, signedVersion, signedPlatform, r.executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(r.executable)
+	if r.sha256 == "" {
+		r.sha256 = hex.EncodeToString(digest[:])
+	}
+	metadata, err := json.Marshal(&Release{
+		Version: r.version,
+		Executables: map[string]*Executable{
+			Platform(): {URL: "bin/signer", SHA256: r.sha256, SignatureURL: "bin/signer.sig"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+MetadataFilename, func(w http.ResponseWriter, r *http.Request) { w.Write(metadata) })
+	mux.HandleFunc("/bin/signer", func(w http.ResponseWriter, req *http.Request) { w.Write(r.executable) })
+	mux.HandleFunc("/bin/signer.sig", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(signature)) })
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL + "/" + MetadataFilename
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newTestUpdater returns an updater of an installed executable holding
+// "current executable".
+func newTestUpdater(t *testing.T, releaseKey *ecdsa.PrivateKey, url string) *Updater {
+	t.Helper()
+	name := "independent-signer"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	execPath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(execPath, []byte("current executable"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Updater{
+		URL:       url,
+		PublicKey: crypto.FromECDSAPub(&releaseKey.PublicKey),
+		ExecPath:  execPath,
+		Version:   "v1.0.0",
+	}
+}
+
+func testExecutable(t *testing.T) []byte {
+	t.Helper()
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executable, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return executable
+}
+
+func assertUnchanged(t *testing.T, updater *Updater) {
+	t.Helper()
+	if current, err := os.ReadFile(updater.ExecPath); err != nil || string(current) != "current executable" {
+		t.Errorf("the executable was replaced: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	releaseKey := generateKey(t)
+	release := &testRelease{version: "v1.2.0", executable: testExecutable(t), signingKey: releaseKey}
+	updater := newTestUpdater(t, releaseKey, release.serve(t))
+	t.Setenv(versionEnv, "v1.2.0")
+
+	result, err := updater.Update(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Updated || result.From != "v1.0.0" || result.To != "v1.2.0" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if installed, err := os.ReadFile(updater.ExecPath); err != nil || !bytes.Equal(installed, release.executable) {
+		t.Errorf("the new executable was not installed: %v", err)
+	}
+	if old, err := os.ReadFile(updater.ExecPath + ".old"); err != nil || string(old) != "current executable" {
+		t.Errorf("the previous executable was not kept: %v", err)
+	}
+
+	// The installed version is now the latest.
+	updater.Version = "v1.2.0"
+	if result, err := updater.Update(context.Background()); err != nil || result.Updated {
+		t.Errorf("updated again: %+v, %v", result, err)
+	}
+}
+
+func TestUpdateRefusesBadReleases(t *testing.T) {
+	releaseKey := generateKey(t)
+	tests := []struct {
+		name    string
+		release *testRelease
+		err     error
+	}{
+		{"signed by another key", &testRelease{signingKey: generateKey(t)}, ErrBadSignature},
+		{"signed as another version", &testRelease{signingKey: releaseKey, signedVersion: "v1.1.0"}, ErrBadSignature},
+		{"signed for another platform", &testRelease{signingKey: releaseKey, signedPlatform: "plan9_386"}, ErrBadSignature},
+		{"checksum mismatch", &testRelease{signingKey: releaseKey, sha256: strings.Repeat("00", sha256.Size)}, nil},
+	}
+	for _, test := range tests {
+		test.release.version = "v1.2.0"
+		test.release.executable = []byte("new executable")
+		updater := newTestUpdater(t, releaseKey, test.release.serve(t))
+
+		_, err := updater.Update(context.Background())
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+		assertUnchanged(t, updater)
+	}
+}
+
+func TestUpdateRollsBack(t *testing.T) {
+	releaseKey := generateKey(t)
+	tests := []struct {
+		name       string
+		executable []byte
+		reports    string
+	}{
+		{"does not start", []byte("not an executable"), ""},
+		{"reports another version", nil, "v1.1.0"},
+	}
+	for _, test := range tests {
+		executable := test.executable
+		if executable == nil {
+			executable = testExecutable(t)
+		}
+		release := &testRelease{version: "v1.2.0", executable: executable, signingKey: releaseKey}
+		updater := newTestUpdater(t, releaseKey, release.serve(t))
+		t.Setenv(versionEnv, test.reports)
+
+		if _, err := updater.Update(context.Background()); err == nil || !strings.Contains(err.Error(), "previous executable was restored") {
+			t.Errorf("%s: got %v, want a rollback", test.name, err)
+		}
+		assertUnchanged(t, updater)
+	}
+}
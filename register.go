@@ -0,0 +1,83 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+)
+
+func runRegisterCommand(ctx context.Context, engine *signer.Engine, args []string) {
+	flags := flag.NewFlagSet("register", flag.ExitOnError)
+	token := flags.String("token", "", "Registration token generated in the Coiin Console (without one, a challenge from the registration endpoint is signed)")
+	flags.Parse(args)
+
+	registration, err := engine.Register(ctx, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printRegistration(registration)
+}
+
+// runRegistrationCommand checks the registration of the signing key. It exits
+// with status 1 unless the key is registered, so scripts can check it.
+func runRegistrationCommand(ctx context.Context, engine *signer.Engine, action string) {
+	if action != "status" {
+		log.Fatalf("usage: independent-signer registration status")
+	}
+
+	registration, err := engine.RegistrationStatus(ctx)
+	if err != nil {
+		log.Fatalf("failed to check the registration: %s", err)
+	}
+	printRegistration(registration)
+	if registration.Status != signer.RegistrationRegistered {
+		os.Exit(1)
+	}
+}
+
+func printRegistration(registration *signer.Registration) {
+	fmt.Printf("Public Key:     %s\n", registration.PublicKey)
+	switch registration.Status {
+	case signer.RegistrationRegistered:
+		if registration.RegisteredAt != nil {
+			fmt.Printf("Registration:   registered since %s\n", registration.RegisteredAt.Local().Format(time.RFC1123))
+		} else {
+			fmt.Println("Registration:   registered")
+		}
+	case signer.RegistrationPending:
+		fmt.Println("Registration:   pending")
+		if registration.ClaimURL != "" {
+			fmt.Printf("Open %s to link the Public Key to your Coiin Console account.\n", registration.ClaimURL)
+		}
+	case signer.RegistrationUnregistered:
+		fmt.Println("Registration:   not registered, the NVL Proxy refuses blocks signed with unregistered keys")
+		fmt.Println("Register it with: independent-signer register")
+	default:
+		fmt.Printf("Registration:   %s\n", registration.Status)
+	}
+	if registration.Message != "" {
+		fmt.Println(registration.Message)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Coiin
+// Licensed under the Apache License, Version 2.0 (the "Apache License")
+// with the following modification; you may not use this file except in
+// compliance with the Apache License and the following modification to it:
+// Section 6. Trademarks. is deleted and replaced with:
+//      6. Trademarks. This License does not grant permission to use the trade
+//         names, trademarks, service marks, or product names of the Licensor
+//         and its affiliates, except as required to comply with Section 4(c) of
+//         the License and to reproduce the content of the NOTICE file.
+// You may obtain a copy of the Apache License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the Apache License with the above modification is
+// distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the Apache License for the specific
+// language governing permissions and limitations under the Apache License.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/signer"
+	"github.com/Coiin-Blockchain/nvl-independent-signer/pkg/update"
+)
+
+// ReleasePublicKey is the hex encoded public key releases are signed with. It
+// is set at build time with -ldflags "-X main.ReleasePublicKey=04...", and
+// builds without it cannot update themselves.
+var ReleasePublicKey = ""
+
+// UpdateSettings configure updates of the signer executable.
+type UpdateSettings struct {
+	URL           string
+	Auto          bool
+	CheckInterval time.Duration
+}
+
+func newUpdater(engine *signer.Engine, settings UpdateSettings) (*update.Updater, error) {
+	var publicKey []byte
+	if ReleasePublicKey != "" {
+		var err error
+		if publicKey, err = hex.DecodeString(ReleasePublicKey); err != nil {
+			return nil, fmt.Errorf("invalid release public key: %w", err)
+		}
+	}
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the executable: %w", err)
+	}
+	if execPath, err = filepath.EvalSymlinks(execPath); err != nil {
+		return nil, err
+	}
+	return &update.Updater{
+		URL:       settings.URL,
+		PublicKey: publicKey,
+		HTTP:      engine.HTTP,
+		ExecPath:  execPath,
+		Version:   Version,
+	}, nil
+}
+
+func runUpdateCommand(ctx context.Context, engine *signer.Engine, settings UpdateSettings, args []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	check := flags.Bool("check", false, "Only report whether a newer release is available")
+	flags.Parse(args)
+
+	updater, err := newUpdater(engine, settings)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *check {
+		release, newer, err := updater.Check(ctx)
+		if err != nil {
+			log.Fatalf("failed to check for updates: %s", err)
+		}
+		if newer {
+			log.Printf("Version %s is available, this is %s\n", release.Version, Version)
+		} else {
+			log.Printf("Version %s is the latest release\n", Version)
+		}
+		return
+	}
+
+	result, err := updater.Update(ctx)
+	if err != nil {
+		log.Fatalf("failed to update: %s", err)
+	}
+	if result.Updated {
+		log.Printf("Updated %s from %s to %s\n", updater.ExecPath, result.From, result.To)
+	} else {
+		log.Printf("Version %s is the latest release\n", result.From)
+	}
+}
+
+// autoUpdate updates the signer after a run if an update check is due. Update
+// failures never fail the run.
+func autoUpdate(ctx context.Context, engine *signer.Engine, settings UpdateSettings) {
+	due, err := engine.UpdateCheckDue(settings.CheckInterval)
+	if err != nil {
+		log.Printf("failed to check for updates: %s", err)
+		return
+	}
+	if !due {
+		return
+	}
+
+	updater, err := newUpdater(engine, settings)
+	if err != nil {
+		log.Printf("failed to check for updates: %s", err)
+		return
+	}
+	result, err := updater.Update(ctx)
+	if err != nil {
+		log.Printf("failed to update: %s", err)
+		return
+	}
+	if result.Updated {
+		log.Printf("Updated from %s to %s, the new version runs next time\n", result.From, result.To)
+	}
+}